@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+
+	"httptask/calendar"
+)
+
+// inviteAttendeesHandler приглашает на событие id пользователей из
+// attendees — id через запятую
+func inviteAttendeesHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := parseIntParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	attendees, err := parseIntListParam(r, "attendees")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	version, err := parseVersion(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	event, err := cal.InviteAttendees(eventID, userID, attendees, version)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	setETag(w, event)
+	writeJSON(w, http.StatusOK, map[string]string{"result": "Attendees invited"})
+}
+
+// respondToInviteHandler сохраняет ответ status (accepted, declined или
+// pending) приглашенного пользователя
+func respondToInviteHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := parseIntParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	event, err := cal.RespondToInvite(eventID, userID, calendar.RSVP(r.FormValue("status")))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	setETag(w, event)
+	writeJSON(w, http.StatusOK, map[string]string{"result": "Response saved"})
+}
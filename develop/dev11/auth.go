@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Режимы аутентификации: none — доверять параметру user_id, как раньше;
+// static — токены из JSON файла {"токен": user_id}; hmac — подписанные
+// токены вида <user_id>.<истечение в unix секундах>.<подпись>.
+
+// authenticator проверяет bearer токен и возвращает id пользователя
+type authenticator interface {
+	authenticate(token string, now time.Time) (int, error)
+}
+
+// Аутентификатор запросов, nil — аутентификация выключена; задается в run
+var auth authenticator
+
+// Пути, доступные без токена
+var publicPaths = map[string]bool{
+	"/metrics":      true,
+	"/openapi.json": true,
+}
+
+var (
+	errMissingToken = &statusError{status: http.StatusUnauthorized, class: errorClassAuth, msg: "missing bearer token"}
+	errInvalidToken = &statusError{status: http.StatusUnauthorized, class: errorClassAuth, msg: "invalid token"}
+	errExpiredToken = &statusError{status: http.StatusUnauthorized, class: errorClassAuth, msg: "token expired"}
+	errUserMismatch = &statusError{status: http.StatusForbidden, class: errorClassAuth, msg: "user_id does not match authenticated user"}
+)
+
+func newAuthenticator(config Config) (authenticator, error) {
+	switch config.Auth {
+	case "static":
+		return loadTokenFile(config.TokenFile)
+	case "hmac":
+		return hmacAuthenticator{secret: []byte(config.AuthSecret)}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// authMiddleware пропускает запрос, только если в нем действительный
+// bearer токен, и кладет аутентифицированного пользователя в контекст.
+// EventSource не умеет ставить заголовки, поэтому токен можно передать
+// и параметром access_token. Запросы без действительного токена
+// ограничиваются по IP адресу через l, иначе перебор токенов ничем
+// не сдерживается.
+func authMiddleware(a authenticator, l *rateLimiter, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := bearerToken(r)
+		if token == "" {
+			if l.reject(w, r) {
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calendar"`)
+			writeError(w, errMissingToken)
+			return
+		}
+		userID, err := a.authenticate(token, time.Now())
+		if err != nil {
+			if l.reject(w, r) {
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calendar", error="invalid_token"`)
+			writeError(w, err)
+			return
+		}
+
+		if rec, ok := w.(userRecorder); ok {
+			rec.recordUser(userID)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey, userID)))
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// requestUserID возвращает пользователя, от имени которого выполняется
+// запрос: аутентифицированного, если аутентификация включена, иначе
+// из параметра user_id. Параметр, не совпадающий с токеном, отклоняется.
+func requestUserID(r *http.Request) (int, error) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		return parseIntParam(r, "user_id")
+	}
+	if r.FormValue("user_id") != "" {
+		claimed, err := parseIntParam(r, "user_id")
+		if err != nil {
+			return 0, err
+		}
+		if claimed != userID {
+			return 0, errUserMismatch
+		}
+	}
+	return userID, nil
+}
+
+// staticAuthenticator — заранее выданные токены из файла
+type staticAuthenticator map[string]int
+
+func loadTokenFile(path string) (staticAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens staticAuthenticator
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for token, userID := range tokens {
+		if token == "" || userID <= 0 {
+			return nil, fmt.Errorf("%s: invalid token for user %d", path, userID)
+		}
+	}
+	return tokens, nil
+}
+
+func (a staticAuthenticator) authenticate(token string, now time.Time) (int, error) {
+	userID, ok := a[token]
+	if !ok {
+		return 0, errInvalidToken
+	}
+	return userID, nil
+}
+
+// hmacAuthenticator проверяет токены, подписанные общим секретом
+type hmacAuthenticator struct {
+	secret []byte
+}
+
+// signToken выдает токен пользователя userID, действующий до expires
+func signToken(secret []byte, userID int, expires time.Time) string {
+	payload := strconv.Itoa(userID) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(secret, payload))
+}
+
+func tokenSignature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (a hmacAuthenticator) authenticate(token string, now time.Time) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, errInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, tokenSignature(a.secret, parts[0]+"."+parts[1])) {
+		return 0, errInvalidToken
+	}
+
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	if !now.Before(time.Unix(expires, 0)) {
+		return 0, errExpiredToken
+	}
+	return userID, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// withAuth включает аутентификацию на время теста
+func withAuth(t *testing.T, a authenticator) {
+	previous := auth
+	auth = a
+	t.Cleanup(func() { auth = previous })
+}
+
+func TestHMACTokens(t *testing.T) {
+	a := hmacAuthenticator{secret: testSecret}
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	token := signToken(testSecret, 7, now.Add(time.Hour))
+
+	if userID, err := a.authenticate(token, now); err != nil || userID != 7 {
+		t.Errorf("authenticate = %d, %v; want 7", userID, err)
+	}
+	if _, err := a.authenticate(token, now.Add(time.Hour)); !errors.Is(err, errExpiredToken) {
+		t.Errorf("Expected expired token error, got %v", err)
+	}
+
+	// подмена пользователя ломает подпись
+	forged := "8" + token[1:]
+	if _, err := a.authenticate(forged, now); !errors.Is(err, errInvalidToken) {
+		t.Errorf("Expected invalid token error for forged token, got %v", err)
+	}
+	other := signToken([]byte("another secret, also 32 bytes long"), 7, now.Add(time.Hour))
+	if _, err := a.authenticate(other, now); !errors.Is(err, errInvalidToken) {
+		t.Errorf("Expected invalid token error for foreign secret, got %v", err)
+	}
+	if _, err := a.authenticate("garbage", now); !errors.Is(err, errInvalidToken) {
+		t.Errorf("Expected invalid token error, got %v", err)
+	}
+}
+
+func TestStaticTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	os.WriteFile(path, []byte(`{"alice-token": 1, "bob-token": 2}`), 0600)
+
+	a, err := loadTokenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := a.authenticate("bob-token", time.Now()); err != nil || userID != 2 {
+		t.Errorf("authenticate = %d, %v; want 2", userID, err)
+	}
+	if _, err := a.authenticate("eve-token", time.Now()); !errors.Is(err, errInvalidToken) {
+		t.Errorf("Expected invalid token error, got %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{"zero": 0}`), 0600)
+	if _, err := loadTokenFile(path); err == nil {
+		t.Error("Expected error for token without user")
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	withAuth(t, staticAuthenticator{"alice-token": 1, "bob-token": 2})
+
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return serveRouter(req)
+	}
+
+	if rr := request("POST", "/create_event?date=2024-06-03&title=Standup", ""); rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 with WWW-Authenticate, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := request("POST", "/create_event?date=2024-06-03&title=Standup", "eve-token"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for unknown token, got %d", rr.Code)
+	}
+
+	// пользователь берется из токена, user_id не обязателен
+	if rr := request("POST", "/create_event?date=2024-06-03&title=Standup", "alice-token"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if events, _ := cal.EventsForDay(1, mustParseDate("2024-06-03")); len(events) != 1 {
+		t.Errorf("Expected event to be created for user 1, got %+v", events)
+	}
+
+	// чужой user_id в запросе отклоняется
+	rr := request("POST", "/delete_event?id=1&user_id=1", "bob-token")
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "does not match") {
+		t.Errorf("Expected 403, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := request("POST", "/delete_event?id=1", "bob-token"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 deleting another user's event, got %d", rr.Code)
+	}
+
+	if rr := request("GET", "/events_for_day?date=2024-06-03&access_token=alice-token", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected access_token parameter to authenticate, got %d", rr.Code)
+	}
+	if rr := request("GET", "/metrics", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected /metrics to stay public, got %d", rr.Code)
+	}
+}
+
+func TestAuthLogsUser(t *testing.T) {
+	resetEvents()
+	logs := captureLogs(t)
+	withAuth(t, hmacAuthenticator{secret: testSecret})
+
+	req := httptest.NewRequest("GET", "/events_for_day?date=2024-06-03", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(testSecret, 5, time.Now().Add(time.Minute)))
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if entry := decodeLogEntry(t, logs); entry["user_id"] != "5" {
+		t.Errorf("Expected authenticated user in log, got %v", entry)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"httptask/calendar"
+)
+
+// batchHandler атомарно применяет JSON массив операций. Операция — объект
+// с полем op (create, update или delete) и параметрами соответствующего
+// запроса /create_event, /update_event или /delete_event. Все операции
+// выполняются от имени пользователя запроса; при ошибке любой из них
+// не сохраняется ни одна, а в ошибке указывается номер операции.
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var body []map[string]interface{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, errBodyTooLarge)
+			return
+		}
+		writeError(w, &calendar.ValidationError{Msg: "invalid JSON body, expected an array of operations"})
+		return
+	}
+
+	ops := make([]calendar.BatchOp, len(body))
+	for i, fields := range body {
+		if ops[i], err = parseBatchOp(r, userID, fields); err != nil {
+			writeError(w, &calendar.BatchError{Index: i, Err: err})
+			return
+		}
+	}
+
+	results, err := cal.ApplyBatch(userID, ops)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": results})
+}
+
+// parseBatchOp разбирает операцию пакета теми же функциями, что и
+// одиночные запросы: поля объекта подставляются как параметры формы
+func parseBatchOp(r *http.Request, userID int, fields map[string]interface{}) (calendar.BatchOp, error) {
+	values, err := jsonForm(fields)
+	if err != nil {
+		return calendar.BatchOp{}, err
+	}
+	req := (&http.Request{Form: values, Header: make(http.Header)}).WithContext(r.Context())
+
+	if claimed := values.Get("user_id"); claimed != "" && claimed != strconv.Itoa(userID) {
+		return calendar.BatchOp{}, &calendar.ValidationError{Msg: "user_id does not match the batch user"}
+	}
+	if values.Get("occurrence") != "" {
+		return calendar.BatchOp{}, &calendar.ValidationError{Msg: "occurrence is not supported in batch"}
+	}
+
+	op := calendar.BatchOp{Action: calendar.BatchAction(values.Get("op"))}
+	switch op.Action {
+	case calendar.BatchCreate:
+		op.Params, err = parseEventParams(req)
+		return op, err
+	case calendar.BatchUpdate:
+		if op.Params, err = parseEventParams(req); err != nil {
+			return op, err
+		}
+	case calendar.BatchDelete:
+	default:
+		return op, &calendar.ValidationError{Msg: "invalid op"}
+	}
+
+	if op.ID, err = parseIntParam(req, "id"); err != nil {
+		return op, err
+	}
+	op.Version, err = parseVersion(req)
+	return op, err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postBatch(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/batch?user_id=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return serveRouter(req)
+}
+
+func TestBatchHandler(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	postForm(createEventHandler, "/create_event", map[string][]string{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Standup"}})
+
+	rr := postBatch(`[
+		{"op": "create", "date": "2024-06-04", "start_time": "10:00", "duration": "1h", "title": "Planning"},
+		{"op": "update", "id": 1, "version": 1, "date": "2024-06-03", "title": "Daily"},
+		{"op": "delete", "id": 1, "version": 2}
+	]`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	expected := `{"result":[{"op":"create","id":2,"version":1},{"op":"update","id":1,"version":2},{"op":"delete","id":1}]}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("Unexpected body: got %s want %s", rr.Body.String(), expected)
+	}
+
+	rr = serveRouter(httptest.NewRequest("GET", "/events_for_week?user_id=1&date=2024-06-03", nil))
+	if body := rr.Body.String(); strings.Contains(body, "Daily") || !strings.Contains(body, "Planning") {
+		t.Errorf("Unexpected events after batch: %s", body)
+	}
+}
+
+func TestBatchHandlerErrors(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		error  string
+	}{
+		{"not an array", `{"op": "create"}`, http.StatusBadRequest, "invalid JSON body, expected an array of operations"},
+		{"empty", `[]`, http.StatusBadRequest, "batch is empty"},
+		{"invalid op", `[{"op": "move"}]`, http.StatusBadRequest, "operation 0: invalid op"},
+		{"invalid date", `[{"op": "create", "date": "2024-06-04", "title": "A"}, {"op": "create", "date": "tomorrow"}]`, http.StatusBadRequest, "operation 1: invalid date"},
+		{"other user", `[{"op": "create", "user_id": 2, "date": "2024-06-04", "title": "A"}]`, http.StatusBadRequest, "operation 0: user_id does not match the batch user"},
+		{"missing event", `[{"op": "create", "date": "2024-06-04", "title": "A"}, {"op": "delete", "id": 42}]`, http.StatusServiceUnavailable, "operation 1: event not found"},
+	}
+	for _, tt := range tests {
+		rr := postBatch(tt.body)
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, rr.Code)
+		}
+		if expected := `{"error":"` + tt.error + `"}`; strings.TrimSpace(rr.Body.String()) != expected {
+			t.Errorf("%s: got %s want %s", tt.name, rr.Body.String(), expected)
+		}
+	}
+
+	// ни одна операция отклоненных пакетов не сохранилась
+	rr := serveRouter(httptest.NewRequest("GET", "/events_for_day?user_id=1&date=2024-06-04", nil))
+	if body := strings.TrimSpace(rr.Body.String()); body != "null" {
+		t.Errorf("Expected no events, got %s", body)
+	}
+}
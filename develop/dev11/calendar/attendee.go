@@ -0,0 +1,151 @@
+package calendar
+
+// RSVP — ответ участника на приглашение
+type RSVP string
+
+const (
+	RSVPPending  RSVP = "pending"
+	RSVPAccepted RSVP = "accepted"
+	RSVPDeclined RSVP = "declined"
+)
+
+// Наибольшее число участников события
+const maxAttendees = 100
+
+// Attendee — приглашенный на событие пользователь и его ответ
+type Attendee struct {
+	UserID int  `json:"user_id"`
+	Status RSVP `json:"status"`
+}
+
+// attendee возвращает индекс участника userID в e.Attendees или -1
+func (e Event) attendee(userID int) int {
+	for i, a := range e.Attendees {
+		if a.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// attends проверяет, есть ли событие в календаре пользователя userID:
+// он владелец или приглашен и не отказался
+func (e Event) attends(userID int) bool {
+	if e.UserID == userID {
+		return true
+	}
+	i := e.attendee(userID)
+	return i >= 0 && e.Attendees[i].Status != RSVPDeclined
+}
+
+// recipients — пользователи, которым рассылаются изменения события
+func (e Event) recipients() []int {
+	users := []int{e.UserID}
+	for _, a := range e.Attendees {
+		users = append(users, a.UserID)
+	}
+	return users
+}
+
+// InviteAttendees приглашает пользователей userIDs на событие id. Приглашать
+// может только владелец; новые участники получают статус pending, уже
+// приглашенные сохраняют свой ответ. Приглашение на серию распространяется
+// на ее измененные вхождения. Возвращает событие после изменения.
+func (s *Service) InviteAttendees(id, userID int, userIDs []int, version int) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := s.begin(userID)
+
+	event, err := s.ownedEvent(id, userID)
+	if err != nil {
+		return Event{}, err
+	}
+	if err := checkVersion(event, version); err != nil {
+		return Event{}, err
+	}
+	if len(userIDs) == 0 {
+		return Event{}, &ValidationError{Msg: "no attendees to invite"}
+	}
+	for _, invited := range userIDs {
+		if invited <= 0 || invited == userID {
+			return Event{}, &ValidationError{Msg: "invalid attendee"}
+		}
+	}
+
+	return s.updateSeries(op, event, func(e *Event) {
+		for _, invited := range userIDs {
+			if e.attendee(invited) < 0 {
+				e.Attendees = append(e.Attendees, Attendee{UserID: invited, Status: RSVPPending})
+			}
+		}
+	}, func(e Event) error {
+		if len(e.Attendees) > maxAttendees {
+			return &ValidationError{Msg: "too many attendees"}
+		}
+		return nil
+	})
+}
+
+// RespondToInvite сохраняет ответ участника userID на приглашение на
+// событие id; для серии ответ относится ко всем ее вхождениям
+func (s *Service) RespondToInvite(id, userID int, status RSVP) (Event, error) {
+	switch status {
+	case RSVPPending, RSVPAccepted, RSVPDeclined:
+	default:
+		return Event{}, &ValidationError{Msg: "invalid status"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := s.begin(userID)
+
+	event, err := s.store.Get(id)
+	if err != nil {
+		return Event{}, internal(err)
+	}
+	if event.attendee(userID) < 0 {
+		return Event{}, ErrNotInvited
+	}
+
+	return s.updateSeries(op, event, func(e *Event) {
+		if i := e.attendee(userID); i >= 0 {
+			e.Attendees[i].Status = status
+		}
+	}, nil)
+}
+
+// updateSeries применяет change к событию и, если это серия, к ее
+// измененным вхождениям. check, если задан, проверяет событие после
+// изменения до записи. Возвращает измененное событие.
+func (s *Service) updateSeries(op *operation, event Event, change func(*Event), check func(Event) error) (Event, error) {
+	events := []Event{event}
+	if event.RRule != nil {
+		stored, err := s.store.List()
+		if err != nil {
+			return Event{}, internal(err)
+		}
+		for _, e := range stored {
+			if e.RecurrenceID == event.ID {
+				events = append(events, e)
+			}
+		}
+	}
+
+	for i := range events {
+		events[i].Attendees = append([]Attendee(nil), events[i].Attendees...)
+		change(&events[i])
+		if check != nil {
+			if err := check(events[i]); err != nil {
+				return Event{}, err
+			}
+		}
+	}
+	for i := range events {
+		updated, err := op.update(events[i])
+		if err != nil {
+			return Event{}, err
+		}
+		events[i] = updated
+	}
+	return events[0], nil
+}
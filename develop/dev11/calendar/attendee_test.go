@@ -0,0 +1,85 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInviteAndRespond(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Planning"})
+
+	invited, err := s.InviteAttendees(event.ID, 1, []int{2, 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invited.Attendees) != 2 || invited.Attendees[0] != (Attendee{UserID: 2, Status: RSVPPending}) || invited.Version != 2 {
+		t.Errorf("Unexpected event after invite: %+v", invited)
+	}
+
+	// приглашение видно в календаре участника до ответа
+	if events, _ := s.EventsForWeek(2, date(2024, 6, 3)); len(events) != 1 || events[0].ID != event.ID {
+		t.Errorf("Expected invited event in attendee's week, got %+v", events)
+	}
+
+	if _, err := s.RespondToInvite(event.ID, 2, RSVPAccepted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RespondToInvite(event.ID, 3, RSVPDeclined); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := s.EventsForDay(3, date(2024, 6, 3)); len(events) != 0 {
+		t.Errorf("Expected declined event to be hidden, got %+v", events)
+	}
+
+	// повторное приглашение не сбрасывает ответ
+	updated, _ := s.InviteAttendees(event.ID, 1, []int{2, 4}, 0)
+	expected := []Attendee{{2, RSVPAccepted}, {3, RSVPDeclined}, {4, RSVPPending}}
+	if len(updated.Attendees) != len(expected) {
+		t.Fatalf("Unexpected attendees: %+v", updated.Attendees)
+	}
+	for i := range expected {
+		if updated.Attendees[i] != expected[i] {
+			t.Errorf("Attendee %d = %+v, want %+v", i, updated.Attendees[i], expected[i])
+		}
+	}
+
+	if _, err := s.InviteAttendees(event.ID, 2, []int{5}, 0); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner for attendee inviting, got %v", err)
+	}
+	if _, err := s.InviteAttendees(event.ID, 1, []int{1}, 0); err == nil {
+		t.Error("Expected owner invite to be rejected")
+	}
+	if _, err := s.RespondToInvite(event.ID, 5, RSVPAccepted); !errors.Is(err, ErrNotInvited) {
+		t.Errorf("Expected ErrNotInvited, got %v", err)
+	}
+	if _, err := s.RespondToInvite(event.ID, 2, "maybe"); err == nil {
+		t.Error("Expected invalid status to be rejected")
+	}
+}
+
+func TestInviteToSeries(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	series, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup", RRule: rrule})
+	s.UpdateOccurrence(series.ID, 1, date(2024, 6, 4), EventParams{Date: date(2024, 6, 4), Title: "Moved"})
+
+	if _, err := s.InviteAttendees(series.ID, 1, []int{2}, 0); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := s.EventsForWeek(2, date(2024, 6, 3))
+	if len(events) != 3 || events[1].Title != "Moved" {
+		t.Errorf("Expected series with its changed occurrence, got %+v", events)
+	}
+
+	// изменения приглашения рассылаются участнику
+	_, changes, cancel := s.Changes().Subscribe(2, "", 0)
+	defer cancel()
+	s.RespondToInvite(series.ID, 2, RSVPDeclined)
+	if change := <-changes; change.Event.ID != series.ID || change.Event.Attendees[0].Status != RSVPDeclined {
+		t.Errorf("Unexpected change: %+v", change)
+	}
+	if events, _ := s.EventsForWeek(2, date(2024, 6, 3)); len(events) != 0 {
+		t.Errorf("Expected declined series to be hidden, got %+v", events)
+	}
+}
@@ -0,0 +1,296 @@
+package calendar
+
+import "time"
+
+// AuditRecord — неизменяемая запись журнала об изменении события.
+// Before пуст у созданного события, After — у удаленного.
+type AuditRecord struct {
+	Seq     int64      `json:"seq"`
+	Op      int64      `json:"op"`
+	Actor   int        `json:"actor"`
+	Time    time.Time  `json:"time"`
+	Type    ChangeType `json:"type"`
+	EventID int        `json:"event_id"`
+	Before  *Event     `json:"before,omitempty"`
+	After   *Event     `json:"after,omitempty"`
+	// Undo — изменение сделано отменой операции
+	Undo bool `json:"undo,omitempty"`
+}
+
+// По умолчанию журнал хранит столько последних записей. Журнал живет
+// только в памяти: после перезапуска история событий и отмена
+// начинаются заново, даже если события хранятся в файле.
+const maxAuditRecords = 10000
+
+// auditLog — журнал изменений в памяти, защищен Service.mu.
+// Записи только добавляются; отмена операции тоже пишется в журнал.
+// Когда записей больше max, самые старые операции забываются целиком:
+// их история недоступна, и отменить их больше нельзя.
+type auditLog struct {
+	records []AuditRecord
+	seq     int64
+	lastOp  int64
+	undone  map[int64]bool
+	max     int
+}
+
+// trim удаляет старые операции, если журнал превысил max. Журнал
+// сокращается до трех четвертей max, чтобы не копировать его при
+// каждой новой записи, и режется только по границе операций.
+func (log *auditLog) trim() {
+	if len(log.records) <= log.max {
+		return
+	}
+	cut := len(log.records) - log.max*3/4
+	for cut < len(log.records) && log.records[cut].Op == log.records[cut-1].Op {
+		cut++
+	}
+	for _, record := range log.records[:cut] {
+		delete(log.undone, record.Op)
+	}
+	log.records = append([]AuditRecord(nil), log.records[cut:]...)
+}
+
+// operation — одно действие пользователя. Все его изменения попадают
+// в журнал с общим номером и отменяются вместе.
+type operation struct {
+	s     *Service
+	id    int64
+	actor int
+	undo  bool
+
+	// атомарная операция публикует изменения только в commit, а при
+	// ошибке откатывается через rollback; start — ее первая запись журнала
+	atomic bool
+	start  int
+}
+
+// begin начинает операцию пользователя actor, вызывается под s.mu
+func (s *Service) begin(actor int) *operation {
+	// журнал сокращается только между операциями, чтобы op.start
+	// оставался верным до конца операции
+	s.audit.trim()
+	s.audit.lastOp++
+	return &operation{s: s, id: s.audit.lastOp, actor: actor, start: len(s.audit.records)}
+}
+
+// create, update и remove сохраняют изменение, ведут версию события,
+// пишут журнал и публикуют изменение в хаб. Все изменения событий
+// проходят через них.
+func (op *operation) create(event Event) (Event, error) {
+	if err := op.s.checkEventLimit(event.UserID, 1); err != nil {
+		return Event{}, err
+	}
+	event.Version = 1
+	event, err := op.s.store.Create(event)
+	if err != nil {
+		return Event{}, internal(err)
+	}
+	op.record(ChangeCreated, event.ID, nil, &event)
+	return event, nil
+}
+
+func (op *operation) update(event Event) (Event, error) {
+	before, err := op.s.store.Get(event.ID)
+	if err != nil {
+		return Event{}, internal(err)
+	}
+	event.Version = before.Version + 1
+	if err := op.s.store.Update(event); err != nil {
+		return Event{}, internal(err)
+	}
+	op.record(ChangeUpdated, event.ID, &before, &event)
+	return event, nil
+}
+
+func (op *operation) remove(event Event) error {
+	if err := op.s.store.Delete(event.ID); err != nil {
+		return internal(err)
+	}
+	op.record(ChangeDeleted, event.ID, &event, nil)
+	return nil
+}
+
+// restore возвращает удаленное событие под прежним id
+func (op *operation) restore(event Event) error {
+	event.Version++
+	if err := op.s.store.Restore(event); err != nil {
+		return internal(err)
+	}
+	op.record(ChangeCreated, event.ID, nil, &event)
+	return nil
+}
+
+// record копирует before и after, чтобы запись журнала и опубликованное
+// изменение не менялись вместе с сохраненным событием
+func (op *operation) record(changeType ChangeType, eventID int, before, after *Event) {
+	if before != nil {
+		copied := before.clone()
+		before = &copied
+	}
+	if after != nil {
+		copied := after.clone()
+		after = &copied
+	}
+	log := &op.s.audit
+	log.seq++
+	record := AuditRecord{
+		Seq:     log.seq,
+		Op:      op.id,
+		Actor:   op.actor,
+		Time:    op.s.now(),
+		Type:    changeType,
+		EventID: eventID,
+		Before:  before,
+		After:   after,
+		Undo:    op.undo,
+	}
+	log.records = append(log.records, record)
+	if !op.atomic {
+		op.s.publish(record)
+	}
+}
+
+func (s *Service) publish(record AuditRecord) {
+	event := record.After
+	if event == nil {
+		event = record.Before
+	}
+	s.changes.publish(record.Type, *event)
+}
+
+// commit публикует изменения атомарной операции
+func (op *operation) commit() {
+	for _, record := range op.s.audit.records[op.start:] {
+		op.s.publish(record)
+	}
+}
+
+// rollback возвращает хранилище в состояние до атомарной операции
+// и убирает ее записи из журнала, как будто ее не было
+func (op *operation) rollback() error {
+	log := &op.s.audit
+	store := op.s.store
+	for i := len(log.records) - 1; i >= op.start; i-- {
+		record := log.records[i]
+		var err error
+		switch record.Type {
+		case ChangeCreated:
+			err = store.Delete(record.EventID)
+		case ChangeUpdated:
+			err = store.Update(record.Before.clone())
+		case ChangeDeleted:
+			err = store.Restore(record.Before.clone())
+		}
+		if err != nil {
+			return internal(err)
+		}
+	}
+	log.seq -= int64(len(log.records) - op.start)
+	log.records = log.records[:op.start]
+	return nil
+}
+
+// EventHistory возвращает журнал изменений события id, в том числе
+// удаленного. Журнал доступен только владельцу события и содержит
+// только изменения, еще не вытесненные из журнала (см. maxAuditRecords).
+func (s *Service) EventHistory(id, userID int) ([]AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []AuditRecord
+	for _, record := range s.audit.records {
+		if record.EventID != id {
+			continue
+		}
+		event := record.After
+		if event == nil {
+			event = record.Before
+		}
+		if event.UserID != userID {
+			return nil, ErrNotOwner
+		}
+		result = append(result, record)
+	}
+	if len(result) == 0 {
+		return nil, ErrEventNotFound
+	}
+	return result, nil
+}
+
+// Undo отменяет последнюю не отмененную операцию пользователя userID
+// и возвращает число отмененных изменений. Повторный вызов отменяет
+// предыдущую операцию. Если затронутые события с тех пор изменил
+// кто-то другой, отмена отклоняется целиком.
+func (s *Service) Undo(userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := &s.audit
+	var target int64
+	for i := len(log.records) - 1; i >= 0; i-- {
+		record := log.records[i]
+		if record.Actor == userID && !record.Undo && !log.undone[record.Op] {
+			target = record.Op
+			break
+		}
+	}
+	if target == 0 {
+		return 0, ErrNothingToUndo
+	}
+
+	var records []AuditRecord
+	last := 0
+	for i, record := range log.records {
+		if record.Op == target {
+			records = append(records, record)
+			last = i
+		}
+	}
+
+	// после операции события могли меняться только отменами более
+	// поздних операций, иначе отмена затрет чужие изменения
+	touched := make(map[int]bool)
+	for _, record := range records {
+		touched[record.EventID] = true
+	}
+	for _, record := range log.records[last+1:] {
+		if touched[record.EventID] && !record.Undo && !log.undone[record.Op] {
+			return 0, ErrUndoConflict
+		}
+	}
+
+	// отмена серии или импорта состоит из многих изменений и при сбое
+	// посередине откатывается целиком, как пакет
+	op := s.begin(userID)
+	op.undo = true
+	op.atomic = true
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		var err error
+		switch record.Type {
+		case ChangeCreated:
+			var current Event
+			if current, err = s.store.Get(record.EventID); err == nil {
+				err = op.remove(current)
+			}
+		case ChangeUpdated:
+			_, err = op.update(record.Before.clone())
+		case ChangeDeleted:
+			err = op.restore(record.Before.clone())
+		}
+		if err != nil {
+			if rollbackErr := op.rollback(); rollbackErr != nil {
+				return 0, rollbackErr
+			}
+			return 0, internal(err)
+		}
+	}
+	op.commit()
+
+	if log.undone == nil {
+		log.undone = make(map[int64]bool)
+	}
+	log.undone[target] = true
+	return len(records), nil
+}
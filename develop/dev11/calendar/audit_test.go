@@ -0,0 +1,226 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEventHistory(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 6, 4), Title: "Retro"})
+	s.DeleteEvent(event.ID, 1, 0)
+
+	records, err := s.EventHistory(event.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %+v", records)
+	}
+	created, updated, deleted := records[0], records[1], records[2]
+	if created.Type != ChangeCreated || created.Before != nil || created.After.Title != "Standup" || created.Actor != 1 || !created.Time.Equal(now) {
+		t.Errorf("Unexpected create record: %+v", created)
+	}
+	if updated.Type != ChangeUpdated || updated.Before.Title != "Standup" || updated.After.Title != "Retro" || updated.After.Version != 2 {
+		t.Errorf("Unexpected update record: %+v", updated)
+	}
+	if deleted.Type != ChangeDeleted || deleted.Before.Title != "Retro" || deleted.After != nil {
+		t.Errorf("Unexpected delete record: %+v", deleted)
+	}
+
+	if _, err := s.EventHistory(event.ID, 2); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner, got %v", err)
+	}
+	if _, err := s.EventHistory(42, 1); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 6, 4), Title: "Retro"})
+	s.CreateEvent(2, EventParams{Date: date(2024, 6, 3), Title: "Other user"})
+
+	// отмена изменения возвращает прежнее состояние с новой версией
+	if n, err := s.Undo(1); err != nil || n != 1 {
+		t.Fatalf("Undo update: n=%d, err=%v", n, err)
+	}
+	restored, _ := s.store.Get(event.ID)
+	if restored.Title != "Standup" || !restored.Date.Equal(date(2024, 6, 3)) || restored.Version != 3 {
+		t.Errorf("Unexpected event after undo: %+v", restored)
+	}
+
+	// следующая отмена идет дальше по истории, а не отменяет отмену
+	if _, err := s.Undo(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.Get(event.ID); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected created event to be removed, got %v", err)
+	}
+	if _, err := s.Undo(1); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected ErrNothingToUndo, got %v", err)
+	}
+
+	if events, _ := s.EventsForDay(2, date(2024, 6, 3)); len(events) != 1 {
+		t.Errorf("Undo must not touch other users' events, got %+v", events)
+	}
+}
+
+func TestUndoDeleteSeries(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	series, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup", RRule: rrule})
+	s.UpdateOccurrence(series.ID, 1, date(2024, 6, 4), EventParams{Date: date(2024, 6, 4), Title: "Moved"})
+	s.DeleteEvent(series.ID, 1, 0)
+
+	if n, err := s.Undo(1); err != nil || n != 2 {
+		t.Fatalf("Expected series and override restored, n=%d, err=%v", n, err)
+	}
+	events, _ := s.EventsForMonth(1, date(2024, 6, 1))
+	titles := make(map[string]int)
+	for _, event := range events {
+		titles[event.Title]++
+	}
+	if titles["Standup"] != 2 || titles["Moved"] != 1 {
+		t.Errorf("Unexpected events after undo: %+v", events)
+	}
+}
+
+// failingStore отказывает в восстановлении события failID
+type failingStore struct {
+	EventStore
+	failID int
+}
+
+func (s *failingStore) Restore(event Event) error {
+	if event.ID == s.failID {
+		return errors.New("disk full")
+	}
+	return s.EventStore.Restore(event)
+}
+
+func TestUndoIsAtomic(t *testing.T) {
+	store := &failingStore{EventStore: NewMemoryStore()}
+	s := NewService(store)
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	series, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup", RRule: rrule})
+	s.UpdateOccurrence(series.ID, 1, date(2024, 6, 4), EventParams{Date: date(2024, 6, 4), Title: "Moved"})
+	s.DeleteEvent(series.ID, 1, 0)
+	history, _ := s.EventHistory(series.ID, 1)
+	_, changes, cancel := s.Changes().Subscribe(1, "", 0)
+	defer cancel()
+
+	// вхождение восстанавливается первым, серия — нет
+	store.failID = series.ID
+	var internalErr *InternalError
+	if _, err := s.Undo(1); !errors.As(err, &internalErr) {
+		t.Fatalf("Expected internal error, got %v", err)
+	}
+	if events, _ := s.EventsForMonth(1, date(2024, 6, 1)); len(events) != 0 {
+		t.Errorf("Expected failed undo to restore nothing, got %+v", events)
+	}
+	if after, _ := s.EventHistory(series.ID, 1); len(after) != len(history) {
+		t.Errorf("Expected failed undo to leave no history, got %+v", after)
+	}
+	select {
+	case change := <-changes:
+		t.Errorf("Unexpected change published: %+v", change)
+	default:
+	}
+
+	store.failID = 0
+	if n, err := s.Undo(1); err != nil || n != 2 {
+		t.Errorf("Expected retried undo to succeed, n=%d, err=%v", n, err)
+	}
+}
+
+func TestAuditRecordsAreImmutable(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=DAILY")
+	series, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 1), Title: "Standup", RRule: rrule})
+	for day := 2; day <= 5; day++ {
+		s.CancelOccurrence(series.ID, 1, date(2024, 6, day), 0)
+	}
+	s.Undo(1)
+	s.CancelOccurrence(series.ID, 1, date(2024, 6, 11), 0)
+
+	// срезы записей не разделяются с сохраненным событием: append
+	// к его ExDates не должен менять уже записанную историю
+	records, _ := s.EventHistory(series.ID, 1)
+	for i, record := range records[1:5] {
+		want := date(2024, 6, i+2)
+		if got := record.After.ExDates; !got[len(got)-1].Equal(want) {
+			t.Errorf("Record %d: expected last exdate %v, got %v", record.Seq, want, got)
+		}
+	}
+	if got := records[5].After.ExDates; len(got) != 3 {
+		t.Errorf("Expected undo to restore 3 exdates, got %v", got)
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 6, 4), Title: "Retro"})
+
+	// событие изменилось после операции, которую отменяют
+	s.mu.Lock()
+	op := s.begin(2)
+	current, _ := s.store.Get(event.ID)
+	current.Title = "Changed by someone else"
+	op.update(current)
+	s.mu.Unlock()
+
+	if _, err := s.Undo(1); !errors.Is(err, ErrUndoConflict) {
+		t.Errorf("Expected ErrUndoConflict, got %v", err)
+	}
+	if current, _ := s.store.Get(event.ID); current.Title != "Changed by someone else" {
+		t.Errorf("Rejected undo must not change the event, got %+v", current)
+	}
+}
+
+func TestAuditLogIsBounded(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	s.audit.max = 8
+
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	first, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "First"})
+	series, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Series", RRule: rrule})
+	s.UpdateOccurrence(series.ID, 1, date(2024, 6, 4), EventParams{Date: date(2024, 6, 4), Title: "Moved"})
+	for i := 0; i < 10; i++ {
+		s.UpdateEvent(first.ID, 1, EventParams{Date: date(2024, 6, 3), Title: "First"})
+	}
+
+	if len(s.audit.records) > s.audit.max {
+		t.Errorf("Expected at most %d records, got %d", s.audit.max, len(s.audit.records))
+	}
+	if _, err := s.EventHistory(series.ID, 1); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected history of old changes to be dropped, got %v", err)
+	}
+	// номера записей продолжают расти после сокращения
+	records, _ := s.EventHistory(first.ID, 1)
+	if last := records[len(records)-1]; last.Seq != 14 {
+		t.Errorf("Expected last seq 14, got %d", last.Seq)
+	}
+
+	// отменяются только операции, оставшиеся в журнале
+	undone := 0
+	for {
+		if _, err := s.Undo(1); err != nil {
+			if !errors.Is(err, ErrNothingToUndo) {
+				t.Fatal(err)
+			}
+			break
+		}
+		undone++
+	}
+	if undone == 0 || undone >= 13 {
+		t.Errorf("Expected only retained operations to be undone, got %d", undone)
+	}
+}
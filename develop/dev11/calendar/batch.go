@@ -0,0 +1,116 @@
+package calendar
+
+import "fmt"
+
+// Действия в пакете изменений
+type BatchAction string
+
+const (
+	BatchCreate BatchAction = "create"
+	BatchUpdate BatchAction = "update"
+	BatchDelete BatchAction = "delete"
+)
+
+// Наибольшее число операций в пакете
+const MaxBatchSize = 100
+
+// BatchOp — одна операция пакета. ID и Version нужны для изменения
+// и удаления, Params — для создания и изменения.
+type BatchOp struct {
+	Action  BatchAction
+	ID      int
+	Version int
+	Params  EventParams
+}
+
+// BatchResult — результат операции пакета: событие после нее,
+// для удаления — только его ID
+type BatchResult struct {
+	Action  BatchAction `json:"op"`
+	ID      int         `json:"id"`
+	Version int         `json:"version,omitempty"`
+}
+
+// BatchError — ошибка операции пакета с номером Index (с нуля).
+// Ошибка любой операции отменяет весь пакет.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyBatch применяет пакет операций пользователя userID атомарно:
+// либо все операции выполняются, либо хранилище остается нетронутым.
+// Пакет — одна операция журнала, /undo отменяет его целиком;
+// подписчики получают изменения только успешного пакета.
+func (s *Service) ApplyBatch(userID int, ops []BatchOp) ([]BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, &ValidationError{Msg: "batch is empty"}
+	}
+	if len(ops) > MaxBatchSize {
+		return nil, &ValidationError{Msg: fmt.Sprintf("batch exceeds %d operations", MaxBatchSize)}
+	}
+	for i, batchOp := range ops {
+		if err := batchOp.validate(); err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := s.begin(userID)
+	op.atomic = true
+
+	results := make([]BatchResult, len(ops))
+	for i, batchOp := range ops {
+		result, err := s.applyBatchOp(op, batchOp)
+		if err != nil {
+			if rollbackErr := op.rollback(); rollbackErr != nil {
+				return nil, rollbackErr
+			}
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results[i] = result
+	}
+	op.commit()
+	return results, nil
+}
+
+func (op BatchOp) validate() error {
+	switch op.Action {
+	case BatchCreate, BatchUpdate:
+		return op.Params.validate()
+	case BatchDelete:
+		return nil
+	default:
+		return &ValidationError{Msg: fmt.Sprintf("unknown op %q", op.Action)}
+	}
+}
+
+func (s *Service) applyBatchOp(op *operation, batchOp BatchOp) (BatchResult, error) {
+	result := BatchResult{Action: batchOp.Action, ID: batchOp.ID}
+	var event Event
+	var err error
+	switch batchOp.Action {
+	case BatchCreate:
+		event, err = s.createEvent(op, batchOp.Params)
+	case BatchUpdate:
+		params := batchOp.Params
+		params.Version = batchOp.Version
+		event, err = s.updateEvent(op, batchOp.ID, params)
+	case BatchDelete:
+		return result, s.deleteEvent(op, batchOp.ID, batchOp.Version)
+	}
+	if err != nil {
+		return BatchResult{}, err
+	}
+	result.ID, result.Version = event.ID, event.Version
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApplyBatch(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	existing, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	doomed, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 4), Title: "Retro"})
+
+	results, err := s.ApplyBatch(1, []BatchOp{
+		{Action: BatchCreate, Params: EventParams{Date: date(2024, 6, 5), Title: "Planning"}},
+		{Action: BatchUpdate, ID: existing.ID, Version: 1, Params: EventParams{Date: date(2024, 6, 3), Title: "Daily"}},
+		{Action: BatchDelete, ID: doomed.ID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []BatchResult{
+		{Action: BatchCreate, ID: 3, Version: 1},
+		{Action: BatchUpdate, ID: existing.ID, Version: 2},
+		{Action: BatchDelete, ID: doomed.ID},
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Errorf("Result %d = %+v, want %+v", i, results[i], expected[i])
+		}
+	}
+
+	// пакет — одна операция журнала и отменяется целиком
+	if n, err := s.Undo(1); err != nil || n != 3 {
+		t.Errorf("Undo = %d, %v; want 3 changes", n, err)
+	}
+}
+
+func TestApplyBatchIsAtomic(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	existing, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	other, _ := s.CreateEvent(2, EventParams{Date: date(2024, 6, 3), Title: "Other"})
+	_, changes, cancel := s.Changes().Subscribe(1, "", 0)
+	defer cancel()
+
+	_, err := s.ApplyBatch(1, []BatchOp{
+		{Action: BatchCreate, Params: EventParams{Date: date(2024, 6, 5), Title: "Planning"}},
+		{Action: BatchUpdate, ID: existing.ID, Params: EventParams{Date: date(2024, 6, 3), Title: "Daily"}},
+		{Action: BatchDelete, ID: existing.ID},
+		{Action: BatchDelete, ID: other.ID},
+	})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 3 || !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("Expected ErrNotOwner at operation 3, got %v", err)
+	}
+
+	events, _ := s.ExportEvents(1, time.Time{}, time.Time{})
+	if len(events) != 1 || events[0].Title != "Standup" || events[0].Version != 1 {
+		t.Errorf("Expected batch to be rolled back, got %+v", events)
+	}
+	if records, _ := s.EventHistory(existing.ID, 1); len(records) != 1 {
+		t.Errorf("Expected rolled back changes to leave no history, got %+v", records)
+	}
+	select {
+	case change := <-changes:
+		t.Errorf("Unexpected change published: %+v", change)
+	default:
+	}
+
+	// неверная операция отклоняется до применения
+	_, err = s.ApplyBatch(1, []BatchOp{
+		{Action: BatchCreate, Params: EventParams{Date: date(2024, 6, 5), Title: "Planning"}},
+		{Action: "move", ID: existing.ID},
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.As(err, &validationErr) {
+		t.Errorf("Expected validation error at operation 1, got %v", err)
+	}
+	if _, err := s.ApplyBatch(1, nil); !errors.As(err, &validationErr) {
+		t.Errorf("Expected empty batch to be rejected, got %v", err)
+	}
+}
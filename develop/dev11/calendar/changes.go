@@ -0,0 +1,149 @@
+package calendar
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Тип изменения события
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeDeleted ChangeType = "deleted"
+	// ChangeReset — пропущенные изменения недоступны: вытеснены из
+	// истории или номер получен от другого запуска. Клиент должен
+	// перечитать события заново; Seq сброса — последнее изменение хаба.
+	ChangeReset ChangeType = "reset"
+)
+
+// Change — изменение события. Seq возрастает на единицу с каждым
+// изменением и позволяет подписчику продолжить с места обрыва.
+type Change struct {
+	Seq   int64      `json:"seq"`
+	Type  ChangeType `json:"type"`
+	Event Event      `json:"event"`
+}
+
+const (
+	// Сколько последних изменений хранится для возобновления подписок
+	changeHistory = 1024
+	// Буфер подписчика; переполненная подписка закрывается
+	subscriberBuffer = 64
+)
+
+// Hub рассылает изменения событий подписчикам их владельцев и участников.
+// Seq начинается заново в каждом процессе, поэтому у хаба есть epoch:
+// номер из другого запуска с тем же Seq ничего не говорит о пропусках.
+type Hub struct {
+	epoch string
+
+	mu          sync.Mutex
+	seq         int64
+	history     []Change
+	subscribers map[int]map[chan Change]struct{}
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		epoch:       strconv.FormatUint(rand.Uint64(), 36),
+		subscribers: make(map[int]map[chan Change]struct{}),
+	}
+}
+
+// Epoch возвращает идентификатор запуска, к которому относятся Seq хаба
+func (h *Hub) Epoch() string {
+	return h.epoch
+}
+
+func (h *Hub) publish(changeType ChangeType, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	change := Change{Seq: h.seq, Type: changeType, Event: event}
+	h.history = append(h.history, change)
+	if len(h.history) > changeHistory {
+		h.history = h.history[len(h.history)-changeHistory:]
+	}
+
+	for _, userID := range event.recipients() {
+		for ch := range h.subscribers[userID] {
+			select {
+			case ch <- change:
+			default:
+				// подписчик не успевает читать: закрываем канал, клиент
+				// переподключится с последним полученным Seq и дочитает историю
+				h.unsubscribe(userID, ch)
+			}
+		}
+	}
+}
+
+// Subscribe подписывает на изменения событий пользователя userID,
+// в том числе событий, куда он приглашен.
+// Если lastSeq не нулевой, сначала возвращаются сохраненные изменения
+// после него, а если их уже нет в истории или epoch не совпадает
+// с Epoch хаба — одно изменение ChangeReset. Канал закрывается при
+// отписке, закрытии хаба или переполнении буфера.
+func (h *Hub) Subscribe(userID int, epoch string, lastSeq int64) ([]Change, <-chan Change, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var backlog []Change
+	if lastSeq > 0 {
+		lost := epoch != h.epoch || lastSeq > h.seq || len(h.history) > 0 && lastSeq < h.history[0].Seq-1
+		if lost {
+			backlog = []Change{{Seq: h.seq, Type: ChangeReset}}
+		} else {
+			for _, change := range h.history {
+				if change.Seq > lastSeq && slices.Contains(change.Event.recipients(), userID) {
+					backlog = append(backlog, change)
+				}
+			}
+		}
+	}
+
+	ch := make(chan Change, subscriberBuffer)
+	if h.closed {
+		close(ch)
+		return backlog, ch, func() {}
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan Change]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+
+	return backlog, ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.unsubscribe(userID, ch)
+	}
+}
+
+func (h *Hub) unsubscribe(userID int, ch chan Change) {
+	if _, ok := h.subscribers[userID][ch]; !ok {
+		return
+	}
+	delete(h.subscribers[userID], ch)
+	if len(h.subscribers[userID]) == 0 {
+		delete(h.subscribers, userID)
+	}
+	close(ch)
+}
+
+// Close закрывает все подписки, новые подписки сразу получают закрытый канал
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for userID, subscribers := range h.subscribers {
+		for ch := range subscribers {
+			h.unsubscribe(userID, ch)
+		}
+	}
+}
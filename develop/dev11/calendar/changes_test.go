@@ -0,0 +1,125 @@
+package calendar
+
+import (
+	"testing"
+)
+
+func TestServicePublishesChanges(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	_, changes, unsubscribe := s.Changes().Subscribe(1, "", 0)
+	defer unsubscribe()
+
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	s.CreateEvent(2, EventParams{Date: date(2024, 6, 3), Title: "Other user"})
+	s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 6, 4), Title: "Retro"})
+	s.DeleteEvent(event.ID, 1, 0)
+
+	want := []struct {
+		seq        int64
+		changeType ChangeType
+		title      string
+	}{
+		{1, ChangeCreated, "Standup"},
+		{3, ChangeUpdated, "Retro"},
+		{4, ChangeDeleted, "Retro"},
+	}
+	for _, w := range want {
+		change := <-changes
+		if change.Seq != w.seq || change.Type != w.changeType || change.Event.Title != w.title || change.Event.ID != event.ID {
+			t.Errorf("Got change %+v, want %+v", change, w)
+		}
+	}
+	select {
+	case change := <-changes:
+		t.Errorf("Unexpected change %+v", change)
+	default:
+	}
+}
+
+func TestHubResumesFromHistory(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	for _, title := range []string{"One", "Two", "Three"} {
+		s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: title})
+	}
+
+	backlog, _, unsubscribe := s.Changes().Subscribe(1, s.Changes().Epoch(), 1)
+	defer unsubscribe()
+	if len(backlog) != 2 || backlog[0].Event.Title != "Two" || backlog[1].Event.Title != "Three" {
+		t.Errorf("Unexpected backlog: %+v", backlog)
+	}
+
+	if backlog, _, unsubscribe := s.Changes().Subscribe(1, "", 0); len(backlog) != 0 {
+		t.Errorf("Expected no backlog for a new subscription, got %+v", backlog)
+		unsubscribe()
+	}
+}
+
+func TestHubResetsOnLostHistory(t *testing.T) {
+	h := NewHub()
+	for i := 0; i < changeHistory+2; i++ {
+		h.publish(ChangeCreated, Event{ID: i + 1, UserID: 1})
+	}
+
+	// изменения 1 и 2 вытеснены из истории: клиент, получивший 2,
+	// ничего не потерял, а получивший только 1 — потерял изменение 2
+	backlog, _, unsubscribe := h.Subscribe(1, h.Epoch(), 2)
+	if len(backlog) != changeHistory || backlog[0].Seq != 3 {
+		t.Errorf("Expected backlog from seq 3, got %d changes", len(backlog))
+	}
+	unsubscribe()
+	for _, lastSeq := range []int64{1, changeHistory + 3} {
+		backlog, _, unsubscribe := h.Subscribe(1, h.Epoch(), lastSeq)
+		if len(backlog) != 1 || backlog[0].Type != ChangeReset || backlog[0].Seq != changeHistory+2 {
+			t.Errorf("lastSeq %d: expected reset, got %+v", lastSeq, backlog)
+		}
+		unsubscribe()
+	}
+}
+
+func TestHubResetsAcrossRestarts(t *testing.T) {
+	before, after := NewHub(), NewHub()
+	for i := 0; i < 60; i++ {
+		after.publish(ChangeCreated, Event{ID: i + 1, UserID: 1})
+	}
+
+	// номер 50 из прошлого запуска не значит, что изменения 1–50 получены
+	backlog, _, unsubscribe := after.Subscribe(1, before.Epoch(), 50)
+	defer unsubscribe()
+	if len(backlog) != 1 || backlog[0].Type != ChangeReset || backlog[0].Seq != 60 {
+		t.Errorf("Expected reset, got %d changes", len(backlog))
+	}
+}
+
+func TestHubClosesSlowSubscriber(t *testing.T) {
+	h := NewHub()
+	_, changes, unsubscribe := h.Subscribe(1, "", 0)
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.publish(ChangeCreated, Event{ID: i, UserID: 1})
+	}
+	n := 0
+	for range changes {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("Expected %d buffered changes before close, got %d", subscriberBuffer, n)
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	h := NewHub()
+	_, changes, unsubscribe := h.Subscribe(1, "", 0)
+	defer unsubscribe()
+
+	h.Close()
+	if _, ok := <-changes; ok {
+		t.Error("Expected subscription to be closed")
+	}
+	if _, changes, _ := h.Subscribe(1, "", 0); changes != nil {
+		if _, ok := <-changes; ok {
+			t.Error("Expected new subscription to a closed hub to be closed")
+		}
+	}
+	h.publish(ChangeCreated, Event{UserID: 1})
+}
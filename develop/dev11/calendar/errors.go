@@ -0,0 +1,76 @@
+package calendar
+
+import "errors"
+
+// ValidationError — ошибка входных данных (HTTP 400).
+// Err — исходная ошибка, если она есть, например ошибка чтения.
+type ValidationError struct {
+	Msg string
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// BusinessError — нарушение правил бизнес-логики (HTTP 503)
+type BusinessError struct {
+	Msg string
+}
+
+func (e *BusinessError) Error() string {
+	return e.Msg
+}
+
+// InternalError — прочие ошибки, например ошибки хранилища (HTTP 500)
+type InternalError struct {
+	Err error
+}
+
+func (e *InternalError) Error() string {
+	return "internal error: " + e.Err.Error()
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+// Ошибки бизнес-логики календаря
+var (
+	ErrEventNotFound      = &BusinessError{Msg: "event not found"}
+	ErrNotOwner           = &BusinessError{Msg: "event belongs to another user"}
+	ErrNotRecurring       = &BusinessError{Msg: "event is not recurring"}
+	ErrOccurrenceNotFound = &BusinessError{Msg: "occurrence not found"}
+	ErrConflict           = &BusinessError{Msg: "event overlaps with another event"}
+	ErrVersionMismatch    = &BusinessError{Msg: "event was modified, reload it and retry"}
+	ErrNothingToUndo      = &BusinessError{Msg: "nothing to undo"}
+	ErrUndoConflict       = &BusinessError{Msg: "events were changed after this change, it cannot be undone"}
+	ErrTooManyEvents      = &BusinessError{Msg: "too many events, delete some and retry"}
+	ErrNotInvited         = &BusinessError{Msg: "user is not invited to the event"}
+)
+
+// Ошибки валидации событий
+var (
+	ErrEmptyTitle      = &ValidationError{Msg: "title is required"}
+	ErrEndBeforeStart  = &ValidationError{Msg: "end must be after start"}
+	ErrInvalidReminder = &ValidationError{Msg: "reminder must be between 0 and 40320 minutes before start"}
+	ErrAllDayTime      = &ValidationError{Msg: "all-day event must start and end at midnight"}
+)
+
+// internal оборачивает в InternalError все ошибки, кроме уже типизированных
+func internal(err error) error {
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	var businessErr *BusinessError
+	var internalErr *InternalError
+	if errors.As(err, &validationErr) || errors.As(err, &businessErr) || errors.As(err, &internalErr) {
+		return err
+	}
+	return &InternalError{Err: err}
+}
@@ -0,0 +1,198 @@
+package calendar
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event — событие календаря
+type Event struct {
+	ID     int       `json:"id"`
+	UserID int       `json:"user_id"`
+	Date   time.Time `json:"date"`
+	Title  string    `json:"title"`
+
+	// Версия растет с каждым изменением, по ней отклоняются правки
+	// поверх устаревшего состояния
+	Version int `json:"version"`
+
+	// Внешний идентификатор из iCalendar, по нему повторный импорт обновляет событие
+	UID string `json:"uid,omitempty"`
+
+	// Окончание события; nil — событие без длительности
+	End *time.Time `json:"end,omitempty"`
+	// IANA зона события, по ней разворачиваются повторения; пустая — UTC
+	TimeZone string `json:"time_zone,omitempty"`
+
+	// Правило повторения; для повторяющегося события Date — первое вхождение
+	RRule *Recurrence `json:"rrule,omitempty"`
+	// Отмененные или измененные вхождения серии
+	ExDates []time.Time `json:"exdates,omitempty"`
+
+	// Для измененного вхождения серии: id серии и исходная дата вхождения
+	RecurrenceID int        `json:"recurrence_id,omitempty"`
+	OriginalDate *time.Time `json:"original_date,omitempty"`
+
+	// Напоминания: за сколько минут до начала каждого вхождения уведомить
+	Reminders []int `json:"reminders,omitempty"`
+
+	// Приглашенные пользователи; событие попадает и в их выборки
+	Attendees []Attendee `json:"attendees,omitempty"`
+
+	Description string `json:"description,omitempty"`
+	Location    string `json:"location,omitempty"`
+	// Цвет вида #RRGGBB и категории из Categories
+	Color      string   `json:"color,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	// Событие на весь день: Date и End — полночь в зоне события
+	AllDay bool `json:"all_day,omitempty"`
+}
+
+// EventParams — изменяемые поля события для CreateEvent и UpdateEvent
+type EventParams struct {
+	Date     time.Time
+	End      *time.Time
+	TimeZone string
+	Title    string
+	RRule    *Recurrence
+
+	Description string
+	Location    string
+	Color       string
+	Categories  []string
+	AllDay      bool
+
+	// Напоминания в минутах до начала события
+	Reminders []int
+
+	// RejectConflicts — отклонить изменение, если событие пересечется
+	// с другими событиями пользователя; в событии не сохраняется
+	RejectConflicts bool
+	// Version — ожидаемая версия изменяемого события, 0 — без проверки
+	Version int
+}
+
+func (p EventParams) apply(event *Event) {
+	event.Date = p.Date
+	event.End = p.End
+	event.TimeZone = p.TimeZone
+	event.Title = p.Title
+	event.RRule = p.RRule
+	event.Reminders = p.Reminders
+	event.Description = p.Description
+	event.Location = p.Location
+	event.Color = p.Color
+	event.Categories = p.Categories
+	event.AllDay = p.AllDay
+}
+
+// params возвращает изменяемые поля события
+func (e Event) params() EventParams {
+	return EventParams{
+		Date:      e.Date,
+		End:       e.End,
+		TimeZone:  e.TimeZone,
+		Title:     e.Title,
+		RRule:     e.RRule,
+		Reminders: e.Reminders,
+
+		Description: e.Description,
+		Location:    e.Location,
+		Color:       e.Color,
+		Categories:  e.Categories,
+		AllDay:      e.AllDay,
+	}
+}
+
+// clone возвращает копию события, не разделяющую с ним срезы: append
+// к срезу сохраненного события иначе может переписать чужую копию
+func (e Event) clone() Event {
+	e.ExDates = slices.Clone(e.ExDates)
+	e.Reminders = slices.Clone(e.Reminders)
+	e.Attendees = slices.Clone(e.Attendees)
+	e.Categories = slices.Clone(e.Categories)
+	return e
+}
+
+// Кэш зон: time.LoadLocation читает базу зон с диска при каждом вызове
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// location возвращает зону события, для неизвестной зоны — UTC
+func (e Event) location() *time.Location {
+	loc, err := loadLocation(e.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (e Event) duration() time.Duration {
+	if e.End == nil {
+		return 0
+	}
+	return e.End.Sub(e.Date)
+}
+
+// overlaps проверяет, пересекается ли событие с [from, to).
+// Событие без длительности попадает в интервал своим началом.
+func (e Event) overlaps(from, to time.Time) bool {
+	if e.End == nil {
+		return !e.Date.Before(from) && e.Date.Before(to)
+	}
+	return e.Date.Before(to) && e.End.After(from)
+}
+
+// occurrences разворачивает событие во вхождения, пересекающиеся с [from, to).
+// Обычное событие дает не больше одного вхождения.
+func (e Event) occurrences(from, to time.Time) []Event {
+	if e.RRule == nil {
+		if e.overlaps(from, to) {
+			return []Event{e}
+		}
+		return nil
+	}
+
+	// повторения считаются по местному времени зоны события,
+	// чтобы встреча в 9:00 оставалась в 9:00 после перехода на летнее время
+	start := e.Date.In(e.location())
+	duration := e.duration()
+
+	var result []Event
+	for _, date := range e.RRule.Occurrences(start, from.Add(-duration), to) {
+		if e.isExcluded(date) {
+			continue
+		}
+		occurrence := e
+		occurrence.Date = date
+		if e.End != nil {
+			end := date.Add(duration)
+			occurrence.End = &end
+		}
+		if occurrence.overlaps(from, to) {
+			result = append(result, occurrence)
+		}
+	}
+	return result
+}
+
+func (e Event) isExcluded(date time.Time) bool {
+	for _, exdate := range e.ExDates {
+		if exdate.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+)
+
+// Горизонт проверки пересечений для повторяющихся событий: бесконечную
+// серию нельзя проверить целиком, поэтому проверяется год от ее начала
+const conflictHorizon = 366 * 24 * time.Hour
+
+// Максимальный период запросов занятости и событий за произвольный период
+const maxQueryRange = 366 * 24 * time.Hour
+
+// Interval — полуинтервал времени [Start, End)
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// FreeBusy — занятость пользователей за период: занятые интервалы каждого
+// пользователя и свободные для всех одновременно окна
+type FreeBusy struct {
+	Busy map[int][]Interval `json:"busy"`
+	Free []Interval         `json:"free"`
+}
+
+// FreeBusy возвращает занятость пользователей userIDs в [from, to).
+// Время занимают только события с длительностью.
+func (s *Service) FreeBusy(userIDs []int, from, to time.Time) (FreeBusy, error) {
+	if err := validateRange(from, to); err != nil {
+		return FreeBusy{}, err
+	}
+
+	events, err := s.store.List()
+	if err != nil {
+		return FreeBusy{}, internal(err)
+	}
+
+	result := FreeBusy{Busy: make(map[int][]Interval, len(userIDs))}
+	var all []Interval
+	for _, userID := range userIDs {
+		busy := busyIntervals(events, from, to, func(e Event) bool { return e.attends(userID) })
+		result.Busy[userID] = busy
+		all = append(all, busy...)
+	}
+	result.Free = freeIntervals(mergeIntervals(all), from, to)
+	return result, nil
+}
+
+func validateRange(from, to time.Time) error {
+	if !to.After(from) {
+		return &ValidationError{Msg: "to must be after from"}
+	}
+	if to.Sub(from) > maxQueryRange {
+		return &ValidationError{Msg: "period is too long"}
+	}
+	return nil
+}
+
+// checkOverlaps проверяет, что вхождения candidate не пересекаются
+// с другими событиями того же пользователя
+func checkOverlaps(candidate Event, events []Event) error {
+	if candidate.End == nil {
+		return nil
+	}
+	from, to := candidate.Date, *candidate.End
+	if candidate.RRule != nil {
+		to = candidate.Date.Add(conflictHorizon)
+	}
+
+	busy := busyIntervals(events, from, to, func(e Event) bool {
+		return e.attends(candidate.UserID) && !sameSeries(candidate, e)
+	})
+	for _, occurrence := range candidate.occurrences(from, to) {
+		i := sort.Search(len(busy), func(i int) bool { return busy[i].End.After(occurrence.Date) })
+		if i < len(busy) && busy[i].Start.Before(*occurrence.End) {
+			return ErrConflict
+		}
+	}
+	return nil
+}
+
+// sameSeries проверяет, что e — само событие candidate, его серия
+// или ее измененное вхождение; с ними candidate не конфликтует
+func sameSeries(candidate, e Event) bool {
+	if candidate.ID != 0 && (e.ID == candidate.ID || e.RecurrenceID == candidate.ID) {
+		return true
+	}
+	return candidate.RecurrenceID != 0 && e.ID == candidate.RecurrenceID
+}
+
+// busyIntervals возвращает объединенные занятые интервалы событий,
+// подходящих под match, обрезанные по [from, to)
+func busyIntervals(events []Event, from, to time.Time, match func(Event) bool) []Interval {
+	var intervals []Interval
+	for _, event := range events {
+		if event.End == nil || !match(event) {
+			continue
+		}
+		for _, occurrence := range event.occurrences(from, to) {
+			interval := Interval{Start: occurrence.Date, End: *occurrence.End}
+			if interval.Start.Before(from) {
+				interval.Start = from
+			}
+			if interval.End.After(to) {
+				interval.End = to
+			}
+			intervals = append(intervals, interval)
+		}
+	}
+	return mergeIntervals(intervals)
+}
+
+// mergeIntervals сортирует интервалы и склеивает пересекающиеся и смежные
+func mergeIntervals(intervals []Interval) []Interval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+	merged := make([]Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// freeIntervals возвращает дополнение отсортированных busy до [from, to)
+func freeIntervals(busy []Interval, from, to time.Time) []Interval {
+	free := []Interval{}
+	cursor := from
+	for _, interval := range busy {
+		if interval.Start.After(cursor) {
+			free = append(free, Interval{Start: cursor, End: interval.Start})
+		}
+		if interval.End.After(cursor) {
+			cursor = interval.End
+		}
+	}
+	if to.After(cursor) {
+		free = append(free, Interval{Start: cursor, End: to})
+	}
+	return free
+}
@@ -0,0 +1,107 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2024, 6, day, hour, minute, 0, 0, time.UTC)
+}
+
+func timed(title string, start, end time.Time) EventParams {
+	return EventParams{Date: start, End: &end, Title: title, RejectConflicts: true}
+}
+
+func TestServiceRejectsConflicts(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	meeting, err := s.CreateEvent(1, timed("Meeting", at(3, 10, 0), at(3, 11, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.CreateEvent(1, timed("Overlap", at(3, 10, 30), at(3, 11, 30))); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	// смежные события не пересекаются
+	if _, err := s.CreateEvent(1, timed("Adjacent", at(3, 11, 0), at(3, 12, 0))); err != nil {
+		t.Errorf("Adjacent event must be allowed, got %v", err)
+	}
+	// у другого пользователя свой календарь
+	if _, err := s.CreateEvent(2, timed("Other user", at(3, 10, 0), at(3, 11, 0))); err != nil {
+		t.Errorf("Other user's event must be allowed, got %v", err)
+	}
+	// без опции пересечения разрешены
+	params := timed("Allowed", at(3, 10, 0), at(3, 11, 0))
+	params.RejectConflicts = false
+	if _, err := s.CreateEvent(1, params); err != nil {
+		t.Errorf("Overlap without RejectConflicts must be allowed, got %v", err)
+	}
+
+	// событие не конфликтует само с собой при изменении
+	if _, err := s.UpdateEvent(meeting.ID, 1, timed("Meeting", at(3, 9, 30), at(3, 10, 30))); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict with the allowed overlap, got %v", err)
+	}
+}
+
+func TestServiceRejectsRecurringConflicts(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	s.CreateEvent(1, timed("One-off", at(10, 10, 0), at(10, 11, 0)))
+
+	params := timed("Standup", at(3, 10, 0), at(3, 10, 15))
+	params.RRule, _ = ParseRRule("FREQ=WEEKLY")
+	if _, err := s.CreateEvent(1, params); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for the second occurrence, got %v", err)
+	}
+
+	params.RRule, _ = ParseRRule("FREQ=WEEKLY;BYDAY=TU,TH")
+	if _, err := s.CreateEvent(1, params); err != nil {
+		t.Errorf("Expected no conflict, got %v", err)
+	}
+}
+
+func TestServiceFreeBusy(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	for _, params := range []EventParams{timed("A", at(3, 9, 0), at(3, 10, 0)), timed("B", at(3, 9, 30), at(3, 11, 0))} {
+		params.RejectConflicts = false
+		s.CreateEvent(1, params)
+	}
+	s.CreateEvent(2, timed("C", at(3, 13, 0), at(3, 14, 0)))
+	s.CreateEvent(2, EventParams{Date: at(3, 15, 0), Title: "No duration"})
+
+	result, err := s.FreeBusy([]int{1, 2}, at(3, 8, 0), at(3, 18, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int][]Interval{
+		1: {{at(3, 9, 0), at(3, 11, 0)}},
+		2: {{at(3, 13, 0), at(3, 14, 0)}},
+	}
+	for userID, intervals := range want {
+		if !equalIntervals(result.Busy[userID], intervals) {
+			t.Errorf("Busy[%d] = %v, want %v", userID, result.Busy[userID], intervals)
+		}
+	}
+	free := []Interval{{at(3, 8, 0), at(3, 9, 0)}, {at(3, 11, 0), at(3, 13, 0)}, {at(3, 14, 0), at(3, 18, 0)}}
+	if !equalIntervals(result.Free, free) {
+		t.Errorf("Free = %v, want %v", result.Free, free)
+	}
+
+	if _, err := s.FreeBusy([]int{1}, at(3, 18, 0), at(3, 8, 0)); err == nil {
+		t.Error("Expected error for inverted period")
+	}
+}
+
+func equalIntervals(a, b []Interval) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Start.Equal(b[i].Start) || !a[i].End.Equal(b[i].End) {
+			return false
+		}
+	}
+	return true
+}
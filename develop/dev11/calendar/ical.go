@@ -0,0 +1,419 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Поддерживается подмножество RFC 5545, достаточное для обмена событиями
+// с настольными клиентами: VEVENT с UID, SUMMARY, DESCRIPTION, LOCATION,
+// CATEGORIES, DTSTART, DTEND или DURATION, RRULE, EXDATE и RECURRENCE-ID.
+// Зоны передаются IANA именем в параметре TZID без компонента VTIMEZONE.
+// Цвет события не выгружается: COLOR из RFC 7986 — имя цвета CSS, а не RGB.
+
+const (
+	icalDateTimeUTC = "20060102T150405Z"
+	icalDateTime    = "20060102T150405"
+	icalDate        = "20060102"
+)
+
+// WriteICS записывает события в виде VCALENDAR. Измененные вхождения серии
+// выгружаются отдельными VEVENT с UID серии и RECURRENCE-ID.
+func WriteICS(w io.Writer, events []Event) error {
+	uids := make(map[int]string, len(events))
+	for _, event := range events {
+		uids[event.ID] = event.icalUID()
+	}
+
+	iw := &icalWriter{w: bufio.NewWriter(w)}
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//httptask//calendar//EN")
+	stamp := time.Now().UTC().Format(icalDateTimeUTC)
+	for _, event := range events {
+		uid := event.icalUID()
+		if event.RecurrenceID != 0 {
+			if master, ok := uids[event.RecurrenceID]; ok {
+				uid = master
+			}
+		}
+
+		iw.line("BEGIN:VEVENT")
+		iw.line("UID:" + escapeText(uid))
+		iw.line("DTSTAMP:" + stamp)
+		iw.line("SUMMARY:" + escapeText(event.Title))
+		if event.Description != "" {
+			iw.line("DESCRIPTION:" + escapeText(event.Description))
+		}
+		if event.Location != "" {
+			iw.line("LOCATION:" + escapeText(event.Location))
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = escapeText(category)
+			}
+			iw.line("CATEGORIES:" + strings.Join(categories, ","))
+		}
+		iw.line("DTSTART" + event.icalTime(event.Date))
+		if event.End != nil {
+			iw.line("DTEND" + event.icalTime(*event.End))
+		}
+		if event.RRule != nil {
+			iw.line("RRULE:" + event.RRule.String())
+		}
+		for _, exdate := range event.ExDates {
+			iw.line("EXDATE" + event.icalTime(exdate))
+		}
+		if event.OriginalDate != nil {
+			iw.line("RECURRENCE-ID" + event.icalTime(*event.OriginalDate))
+		}
+		iw.line("END:VEVENT")
+	}
+	iw.line("END:VCALENDAR")
+
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+func (e Event) icalUID() string {
+	if e.UID != "" {
+		return e.UID
+	}
+	return fmt.Sprintf("%d@httptask", e.ID)
+}
+
+// isAllDay — событие на весь день или задано только датой: полночь без длительности
+func (e Event) isAllDay() bool {
+	return e.AllDay || (e.End == nil && isMidnight(e.Date, e.location()))
+}
+
+// mergeICS возвращает сохраненное событие e с полями из iCalendar.
+// Поля, которых в выгрузке нет, остаются прежними: цвет, напоминания,
+// участники и UID, а событие только с датой не становится событием
+// на весь день.
+func (e Event) mergeICS(imported Event) Event {
+	imported.ID = e.ID
+	imported.UserID = e.UserID
+	imported.UID = e.UID
+	imported.Color = e.Color
+	imported.Reminders = e.Reminders
+	imported.Attendees = e.Attendees
+	if imported.AllDay && e.isAllDay() {
+		imported.AllDay = e.AllDay
+	}
+	return imported
+}
+
+// localizeICS переводит даты VALUE=DATE события imported в зону e.
+// Такие даты выгружаются без TZID и читаются как полночь UTC, и без
+// перевода событие на весь день в своей зоне сдвигается при импорте.
+func (e Event) localizeICS(imported Event) Event {
+	if !imported.AllDay || imported.TimeZone != "" || e.TimeZone == "" || !e.isAllDay() {
+		return imported
+	}
+	loc := e.location()
+	inZone := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	imported.TimeZone = e.TimeZone
+	imported.Date = inZone(imported.Date)
+	if imported.End != nil {
+		end := inZone(*imported.End)
+		imported.End = &end
+	}
+	if imported.OriginalDate != nil {
+		original := inZone(*imported.OriginalDate)
+		imported.OriginalDate = &original
+	}
+	exdates := make([]time.Time, len(imported.ExDates))
+	for i, exdate := range imported.ExDates {
+		exdates[i] = inZone(exdate)
+	}
+	imported.ExDates = exdates
+	return imported
+}
+
+// icalTime форматирует момент времени вместе с параметрами свойства:
+// датой для событий на весь день, в зоне события через TZID или в UTC
+func (e Event) icalTime(t time.Time) string {
+	if e.isAllDay() {
+		return ";VALUE=DATE:" + t.In(e.location()).Format(icalDate)
+	}
+	if e.TimeZone != "" {
+		return ";TZID=" + e.TimeZone + ":" + t.In(e.location()).Format(icalDateTime)
+	}
+	return ":" + t.UTC().Format(icalDateTimeUTC)
+}
+
+type icalWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line записывает строку, сворачивая ее по 75 октетов, как требует RFC 5545
+func (iw *icalWriter) line(s string) {
+	if iw.err != nil {
+		return
+	}
+	// строки продолжения начинаются с пробела, он тоже входит в 75 октетов
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		// не разрезаем многобайтовый символ UTF-8
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		if _, iw.err = iw.w.WriteString(s[:cut] + "\r\n "); iw.err != nil {
+			return
+		}
+		s = s[cut:]
+		limit = 74
+	}
+	_, iw.err = iw.w.WriteString(s + "\r\n")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// ReadICS разбирает VEVENT из VCALENDAR. У измененных вхождений серии
+// заполнены UID серии и OriginalDate, связь с серией устанавливает импорт.
+func ReadICS(r io.Reader) ([]Event, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var current *icalEvent
+	for i, line := range lines {
+		name, params, value, err := parseContentLine(line)
+		if err != nil {
+			return nil, icsError(i, err.Error())
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			current = &icalEvent{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if current == nil {
+				return nil, icsError(i, "END:VEVENT without BEGIN")
+			}
+			if current.Date.IsZero() {
+				return nil, icsError(i, "VEVENT without DTSTART")
+			}
+			if current.duration != nil {
+				end := current.Date.Add(*current.duration)
+				current.End = &end
+			}
+			events = append(events, current.Event)
+			current = nil
+		case current != nil:
+			if err := current.setICalProperty(name, params, value); err != nil {
+				return nil, icsError(i, err.Error())
+			}
+		}
+	}
+	if current != nil {
+		return nil, &ValidationError{Msg: "invalid ics: unterminated VEVENT"}
+	}
+	return events, nil
+}
+
+func icsError(line int, msg string) error {
+	return &ValidationError{Msg: fmt.Sprintf("invalid ics: line %d: %s", line+1, msg)}
+}
+
+// icalEvent — VEVENT в процессе разбора. DURATION может идти раньше
+// DTSTART, поэтому окончание вычисляется в конце компонента.
+type icalEvent struct {
+	Event
+	duration *time.Duration
+}
+
+func (e *icalEvent) setICalProperty(name string, params map[string]string, value string) error {
+	switch name {
+	case "UID":
+		e.UID = textUnescaper.Replace(value)
+	case "SUMMARY":
+		e.Title = textUnescaper.Replace(value)
+	case "DESCRIPTION":
+		e.Description = textUnescaper.Replace(value)
+	case "LOCATION":
+		e.Location = textUnescaper.Replace(value)
+	case "CATEGORIES":
+		// категории сравниваются без учета регистра, неизвестные пропускаются,
+		// чтобы файл другого календаря импортировался целиком
+		for _, category := range splitICalList(value) {
+			category = strings.ToLower(textUnescaper.Replace(category))
+			if slices.Contains(Categories, category) && !slices.Contains(e.Categories, category) {
+				e.Categories = append(e.Categories, category)
+			}
+		}
+	case "DTSTART":
+		t, err := parseICalTime(value, params)
+		if err != nil {
+			return err
+		}
+		e.Date = t
+		e.AllDay = params["VALUE"] == "DATE" || len(value) == len(icalDate)
+		if tzid := params["TZID"]; tzid != "" {
+			e.TimeZone = tzid
+		}
+	case "DTEND":
+		t, err := parseICalTime(value, params)
+		if err != nil {
+			return err
+		}
+		e.End = &t
+	case "DURATION":
+		d, err := parseICalDuration(value)
+		if err != nil {
+			return err
+		}
+		e.duration = &d
+	case "RRULE":
+		rrule, err := ParseRRule(value)
+		if err != nil {
+			return err
+		}
+		e.RRule = rrule
+	case "EXDATE":
+		for _, v := range strings.Split(value, ",") {
+			t, err := parseICalTime(v, params)
+			if err != nil {
+				return err
+			}
+			e.ExDates = append(e.ExDates, t)
+		}
+	case "RECURRENCE-ID":
+		t, err := parseICalTime(value, params)
+		if err != nil {
+			return err
+		}
+		e.OriginalDate = &t
+	}
+	return nil
+}
+
+// splitICalList разбивает значение-список по запятым, кроме экранированных
+func splitICalList(value string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// unfoldLines читает строки, склеивая свернутые продолжения. Ошибки
+// чтения — ошибки присланного файла; исходная ошибка сохраняется, чтобы
+// HTTP слой отличил, например, превышение размера тела.
+func unfoldLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		msg := "invalid ics: " + err.Error()
+		if errors.Is(err, bufio.ErrTooLong) {
+			msg = fmt.Sprintf("invalid ics: line %d is too long", len(lines)+1)
+		}
+		return nil, &ValidationError{Msg: msg, Err: err}
+	}
+	return lines, nil
+}
+
+// parseContentLine разбирает строку вида NAME;PARAM=VALUE:value
+func parseContentLine(line string) (string, map[string]string, string, error) {
+	colon := -1
+	quoted := false
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", fmt.Errorf("malformed line %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		key, value, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:], nil
+}
+
+func parseICalTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icalDate) {
+		return time.Parse(icalDate, value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icalDateTimeUTC, value)
+	}
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = loadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown TZID %q", tzid)
+		}
+	}
+	return time.ParseInLocation(icalDateTime, value, loc)
+}
+
+var icalDurationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICalDuration разбирает длительность вида P1DT2H30M
+func parseICalDuration(value string) (time.Duration, error) {
+	m := icalDurationRe.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid DURATION %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
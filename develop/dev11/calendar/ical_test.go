@@ -0,0 +1,187 @@
+package calendar
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestICSRoundTrip(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=WEEKLY;BYDAY=MO;COUNT=4")
+	start := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	series, _ := s.CreateEvent(1, EventParams{Date: start, End: &end, Title: "Sync; weekly, team", RRule: rrule})
+	s.CancelOccurrence(series.ID, 1, start.AddDate(0, 0, 7), 0)
+	s.UpdateOccurrence(series.ID, 1, start.AddDate(0, 0, 14), EventParams{Date: start.AddDate(0, 0, 15), Title: "Moved sync"})
+	s.CreateEvent(1, EventParams{Date: date(2024, 6, 10), Title: strings.Repeat("Очень длинное название ", 5)})
+
+	exported, _ := s.ExportEvents(1, time.Time{}, time.Time{})
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line is not folded: %q", line)
+		}
+	}
+
+	imported := NewService(NewMemoryStore())
+	n, err := imported.ImportICS(1, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 imported events, got %d", n)
+	}
+
+	before, _ := s.EventsForMonth(1, date(2024, 6, 1))
+	after, _ := imported.EventsForMonth(1, date(2024, 6, 1))
+	if len(before) != len(after) {
+		t.Fatalf("Occurrences differ after round trip: %+v vs %+v", before, after)
+	}
+	titles := make(map[string]int)
+	for _, event := range after {
+		titles[event.Title]++
+	}
+	if titles["Sync; weekly, team"] != 2 || titles["Moved sync"] != 1 || titles[strings.Repeat("Очень длинное название ", 5)] != 1 {
+		t.Errorf("Unexpected occurrences after import: %+v", after)
+	}
+}
+
+func TestICSEventDetails(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	end := date(2024, 6, 5)
+	s.CreateEvent(1, EventParams{
+		Date:        date(2024, 6, 3),
+		End:         &end,
+		Title:       "Offsite",
+		Description: "Planning;\nday two: retro",
+		Location:    "Lake house, room 2",
+		Categories:  []string{"work", "travel"},
+		AllDay:      true,
+	})
+
+	exported, _ := s.ExportEvents(1, time.Time{}, time.Time{})
+	var buf bytes.Buffer
+	WriteICS(&buf, exported)
+	if !strings.Contains(buf.String(), "DTEND;VALUE=DATE:20240605") || !strings.Contains(buf.String(), "CATEGORIES:work,travel") {
+		t.Errorf("Unexpected ics:\n%s", buf.String())
+	}
+
+	events, err := ReadICS(strings.NewReader(strings.Replace(buf.String(), "CATEGORIES:work,travel", "CATEGORIES:WORK,Conference\\, EU,travel", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := events[0]
+	if e.Description != "Planning;\nday two: retro" || e.Location != "Lake house, room 2" || !e.AllDay {
+		t.Errorf("Unexpected imported event: %+v", e)
+	}
+	// неизвестные категории пропускаются, известные приводятся к нижнему регистру
+	if len(e.Categories) != 2 || e.Categories[0] != "work" || e.Categories[1] != "travel" {
+		t.Errorf("Unexpected categories: %v", e.Categories)
+	}
+}
+
+func TestImportICSUpdatesByUID(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:abc@example.com\r\nSUMMARY:%s\r\n" +
+		"DURATION:PT1H\r\nDTSTART;TZID=Europe/Moscow:20240530T100000\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	if _, err := s.ImportICS(1, strings.NewReader(strings.Replace(ics, "%s", "First", 1))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ImportICS(1, strings.NewReader(strings.Replace(ics, "%s", "Second", 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := s.ExportEvents(1, time.Time{}, time.Time{})
+	if len(events) != 1 || events[0].Title != "Second" || events[0].TimeZone != "Europe/Moscow" {
+		t.Fatalf("Expected single updated event, got %+v", events)
+	}
+	if events[0].End == nil || events[0].End.Sub(events[0].Date) != time.Hour {
+		t.Errorf("Expected one hour duration, got %+v", events[0])
+	}
+	if got := events[0].Date.UTC(); !got.Equal(time.Date(2024, 5, 30, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected start %v", got)
+	}
+}
+
+func TestImportICSOwnExport(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	series, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup", RRule: rrule, Color: "#FF8800", Reminders: []int{15}})
+	s.UpdateOccurrence(series.ID, 1, date(2024, 6, 4), EventParams{Date: date(2024, 6, 4), Title: "Moved", Color: "#00FF00"})
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	start, end := time.Date(2024, 6, 10, 0, 0, 0, 0, moscow), time.Date(2024, 6, 12, 0, 0, 0, 0, moscow)
+	offsite, err := s.CreateEvent(1, EventParams{Date: start, End: &end, TimeZone: "Europe/Moscow", Title: "Offsite", AllDay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.CreateEvent(2, EventParams{Date: date(2024, 6, 3), Title: "Other user"})
+
+	exported, _ := s.ExportEvents(1, time.Time{}, time.Time{})
+	var buf bytes.Buffer
+	WriteICS(&buf, exported)
+	ics := buf.String()
+
+	// повторный импорт своего экспорта обновляет события, а не дублирует их
+	if _, err := s.ImportICS(1, strings.NewReader(ics)); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := s.EventsForMonth(1, date(2024, 6, 1)); len(events) != 4 {
+		t.Errorf("Expected 4 occurrences after re-import, got %+v", events)
+	}
+	if stored, _ := s.ExportEvents(1, time.Time{}, time.Time{}); len(stored) != len(exported) {
+		t.Errorf("Expected %d stored events, got %+v", len(exported), stored)
+	}
+
+	// поля, которых нет в ics, остаются прежними
+	if got, _ := s.store.Get(series.ID); got.Color != "#FF8800" || len(got.Reminders) != 1 || got.Reminders[0] != 15 || got.AllDay {
+		t.Errorf("Expected color, reminders and kind of series to be kept, got %+v", got)
+	}
+	if got, _ := s.store.Get(series.ID + 1); got.Color != "#00FF00" || got.Title != "Moved" {
+		t.Errorf("Expected override color to be kept, got %+v", got)
+	}
+	// событие на весь день выгружается без TZID и не сдвигается при импорте
+	got, _ := s.store.Get(offsite.ID)
+	if !got.Date.Equal(start) || got.End == nil || !got.End.Equal(end) || got.TimeZone != "Europe/Moscow" || !got.AllDay {
+		t.Errorf("Expected all-day event to stay in its zone, got %+v", got)
+	}
+
+	// чужой экспорт с тем же UID создает новое событие
+	before, _ := s.store.Get(series.ID)
+	if _, err := s.ImportICS(2, strings.NewReader(ics)); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := s.EventsForMonth(2, date(2024, 6, 1)); len(events) != 5 {
+		t.Errorf("Expected imported copy for another user, got %+v", events)
+	}
+	if original, _ := s.store.Get(series.ID); original.Version != before.Version || original.UserID != 1 {
+		t.Errorf("Import by another user must not touch the original, got %+v", original)
+	}
+}
+
+func TestImportICSInvalid(t *testing.T) {
+	for _, ics := range []string{
+		"BEGIN:VEVENT\r\nSUMMARY:No start\r\nEND:VEVENT\r\n",
+		"BEGIN:VEVENT\r\nDTSTART:20240530T100000Z\r\nEND:VEVENT\r\n",
+		"BEGIN:VEVENT\r\nSUMMARY:T\r\nDTSTART:20240530T100000Z\r\nRRULE:FREQ=YEARLY\r\nEND:VEVENT\r\n",
+		"BEGIN:VEVENT\r\nUID:x\r\nSUMMARY:T\r\nDTSTART:20240530T100000Z\r\nRECURRENCE-ID:20240530T100000Z\r\nEND:VEVENT\r\n",
+		"BEGIN:VEVENT\r\nSUMMARY:T\r\nDTSTART:20240530T100000Z\r\n",
+		"BEGIN:VEVENT\r\nSUMMARY:" + strings.Repeat("x", 2<<20) + "\r\n",
+	} {
+		s := NewService(NewMemoryStore())
+		_, err := s.ImportICS(1, strings.NewReader(ics))
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("Expected ValidationError for %q, got %v", ics, err)
+		}
+		if events, _ := s.ExportEvents(1, time.Time{}, time.Time{}); len(events) != 0 {
+			t.Errorf("Nothing must be imported from invalid file, got %+v", events)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package calendar
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sortEvents упорядочивает события по началу, при равенстве — по id,
+// чтобы выдача не зависела от порядка обхода хранилища
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventLess(events[i], events[j])
+	})
+}
+
+func eventLess(a, b Event) bool {
+	if !a.Date.Equal(b.Date) {
+		return a.Date.Before(b.Date)
+	}
+	return a.ID < b.ID
+}
+
+// Paginate возвращает не больше limit событий после cursor из списка,
+// упорядоченного sortEvents, и курсор следующей страницы. Курсор хранит
+// ключ последнего отданного события, поэтому страницы не сдвигаются при
+// добавлении и удалении событий. Нулевой limit — без ограничения.
+func Paginate(events []Event, cursor string, limit int) ([]Event, string, error) {
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		i := sort.Search(len(events), func(i int) bool { return eventLess(after, events[i]) })
+		events = events[i:]
+	}
+	if limit <= 0 || len(events) <= limit {
+		return events, "", nil
+	}
+	events = events[:limit]
+	return events, encodeCursor(events[limit-1]), nil
+}
+
+func encodeCursor(event Event) string {
+	key := fmt.Sprintf("%d:%d", event.Date.UnixNano(), event.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (Event, error) {
+	invalid := &ValidationError{Msg: "invalid cursor"}
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Event{}, invalid
+	}
+	nanos, id, ok := strings.Cut(string(key), ":")
+	if !ok {
+		return Event{}, invalid
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return Event{}, invalid
+	}
+	eventID, err := strconv.Atoi(id)
+	if err != nil {
+		return Event{}, invalid
+	}
+	return Event{ID: eventID, Date: time.Unix(0, n)}, nil
+}
@@ -0,0 +1,68 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEventsAreSorted(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	for _, day := range []int{5, 3, 4, 3} {
+		s.CreateEvent(1, EventParams{Date: date(2024, 6, day), Title: "Event"})
+	}
+
+	events, _ := s.EventsForMonth(1, date(2024, 6, 1))
+	want := []struct{ day, id int }{{3, 2}, {3, 4}, {4, 3}, {5, 1}}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Date.Day() != w.day || events[i].ID != w.id {
+			t.Errorf("events[%d] = %d on day %d, want %d on day %d", i, events[i].ID, events[i].Date.Day(), w.id, w.day)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	for day := 1; day <= 5; day++ {
+		s.CreateEvent(1, EventParams{Date: date(2024, 6, day), Title: "Event"})
+	}
+	events, _ := s.EventsForMonth(1, date(2024, 6, 1))
+
+	var ids []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Pagination does not terminate")
+		}
+		page, next, err := Paginate(events, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range page {
+			ids = append(ids, event.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(ids) != 5 || ids[0] != 1 || ids[4] != 5 {
+		t.Errorf("Unexpected ids across pages: %v", ids)
+	}
+
+	// курсор указывает на событие, а не на позицию в списке
+	_, next, _ := Paginate(events, "", 2)
+	s.CreateEvent(1, EventParams{Date: date(2024, 6, 1), Title: "Inserted before cursor"})
+	events, _ = s.EventsForMonth(1, date(2024, 6, 1))
+	page, _, _ := Paginate(events, next, 1)
+	if len(page) != 1 || page[0].ID != 3 {
+		t.Errorf("Expected page to continue with event 3, got %+v", page)
+	}
+
+	var validationErr *ValidationError
+	if _, _, err := Paginate(events, "bogus!", 2); !errors.As(err, &validationErr) {
+		t.Errorf("Expected ValidationError for invalid cursor, got %v", err)
+	}
+}
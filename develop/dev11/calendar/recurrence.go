@@ -0,0 +1,337 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Частота повторения события
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// WeekdayRule — элемент BYDAY. Ordinal используется только для MONTHLY:
+// 1MO — первый понедельник месяца, -1FR — последняя пятница, 0 — каждый.
+type WeekdayRule struct {
+	Weekday time.Weekday
+	Ordinal int
+}
+
+// Recurrence — правило повторения, подмножество RRULE из RFC 5545:
+// FREQ=DAILY|WEEKLY|MONTHLY, INTERVAL, BYDAY, COUNT, UNTIL.
+// В JSON сериализуется строкой вида "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+type Recurrence struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayRule
+	Count    int
+	Until    time.Time
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseRRule разбирает правило повторения. Префикс "RRULE:" допускается.
+func ParseRRule(s string) (*Recurrence, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	r := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, rruleError("malformed part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, rruleError("invalid INTERVAL %q", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, rruleError("invalid COUNT %q", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, rruleError("invalid UNTIL %q", value)
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				rule, err := parseWeekdayRule(day)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, rule)
+			}
+		default:
+			return nil, rruleError("unsupported part %q", key)
+		}
+	}
+
+	switch r.Freq {
+	case Daily:
+		if len(r.ByDay) > 0 {
+			return nil, rruleError("BYDAY is not supported with FREQ=DAILY")
+		}
+	case Weekly:
+		for _, rule := range r.ByDay {
+			if rule.Ordinal != 0 {
+				return nil, rruleError("BYDAY ordinals are only supported with FREQ=MONTHLY")
+			}
+		}
+	case Monthly:
+	default:
+		return nil, rruleError("unsupported FREQ %q", r.Freq)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, rruleError("COUNT and UNTIL are mutually exclusive")
+	}
+	return r, nil
+}
+
+func rruleError(format string, args ...interface{}) error {
+	return &ValidationError{Msg: "invalid rrule: " + fmt.Sprintf(format, args...)}
+}
+
+func parseWeekdayRule(s string) (WeekdayRule, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayRule{}, rruleError("invalid BYDAY %q", s)
+	}
+	weekday, ok := weekdayCodes[s[len(s)-2:]]
+	if !ok {
+		return WeekdayRule{}, rruleError("invalid BYDAY %q", s)
+	}
+	rule := WeekdayRule{Weekday: weekday}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayRule{}, rruleError("invalid BYDAY %q", s)
+		}
+		rule.Ordinal = n
+	}
+	return rule, nil
+}
+
+// parseUntil принимает UNTIL в форме даты (20240630) или даты-времени
+// (20240630T090000Z). Дата без времени включает весь день.
+func parseUntil(s string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, rule := range r.ByDay {
+			days[i] = weekdayNames[rule.Weekday]
+			if rule.Ordinal != 0 {
+				days[i] = strconv.Itoa(rule.Ordinal) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (r *Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	parsed, err := ParseRRule(string(text))
+	if err != nil {
+		return err
+	}
+	*r = *parsed
+	return nil
+}
+
+// Occurrences возвращает вхождения серии, начинающейся в start, попадающие
+// в полуинтервал [from, to). Время суток и зона вхождений берутся из start.
+func (r *Recurrence) Occurrences(start, from, to time.Time) []time.Time {
+	var result []time.Time
+	n := 0
+	for period := r.firstPeriod(start, from); ; period++ {
+		periodStart, candidates := r.period(start, period)
+		if !periodStart.Before(to) {
+			return result
+		}
+		if !r.Until.IsZero() && periodStart.After(r.Until) {
+			return result
+		}
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return result
+			}
+			n++
+			if r.Count > 0 && n > r.Count {
+				return result
+			}
+			if !candidate.Before(from) && candidate.Before(to) {
+				result = append(result, candidate)
+			}
+		}
+	}
+}
+
+// firstPeriod возвращает номер периода, раньше которого вхождений не
+// позже from нет, чтобы не перебирать серию с самого начала. С COUNT
+// вхождения приходится считать от начала серии, MONTHLY дает не больше
+// 12 периодов в год и перебирается целиком.
+func (r *Recurrence) firstPeriod(start, from time.Time) int {
+	if r.Count > 0 || !from.After(start) {
+		return 0
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	// дни считаются по календарю зоны start, как и в period
+	from = from.In(start.Location())
+	days := int((time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC).Unix() -
+		time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC).Unix()) / 86400)
+
+	var k int
+	switch r.Freq {
+	case Daily:
+		k = days / interval
+	case Weekly:
+		days += (int(start.Weekday()) + 6) % 7
+		k = days / (7 * interval)
+	default:
+		return 0
+	}
+	// на период раньше — запас на переходы между зонами
+	return max(k-1, 0)
+}
+
+// IsOccurrence проверяет, что t — одно из вхождений серии
+func (r *Recurrence) IsOccurrence(start, t time.Time) bool {
+	occurrences := r.Occurrences(start, t, t.Add(time.Nanosecond))
+	return len(occurrences) == 1
+}
+
+// period возвращает начало периода номер k (день, неделя или месяц
+// с учетом INTERVAL) и отсортированные даты-кандидаты внутри него
+func (r *Recurrence) period(start time.Time, k int) (time.Time, []time.Time) {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch r.Freq {
+	case Weekly:
+		// недели начинаются с понедельника (WKST=MO по умолчанию в RFC 5545)
+		monday := start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+		weekStart := monday.AddDate(0, 0, 7*k*interval)
+		if len(r.ByDay) == 0 {
+			return weekStart, []time.Time{start.AddDate(0, 0, 7*k*interval)}
+		}
+		var candidates []time.Time
+		for _, rule := range r.ByDay {
+			offset := (int(rule.Weekday) + 6) % 7
+			candidates = append(candidates, weekStart.AddDate(0, 0, offset))
+		}
+		sortTimes(candidates)
+		return weekStart, candidates
+
+	case Monthly:
+		first := at(start.Year(), start.Month(), 1).AddDate(0, k*interval, 0)
+		if len(r.ByDay) == 0 {
+			candidate := at(first.Year(), first.Month(), start.Day())
+			if candidate.Month() != first.Month() {
+				// в месяце нет такого числа, например 31 февраля
+				return first, nil
+			}
+			return first, []time.Time{candidate}
+		}
+		var candidates []time.Time
+		for _, rule := range r.ByDay {
+			candidates = append(candidates, monthWeekdays(first, rule)...)
+		}
+		sortTimes(candidates)
+		return first, dedupTimes(candidates)
+
+	default:
+		day := start.AddDate(0, 0, k*interval)
+		return day, []time.Time{day}
+	}
+}
+
+// monthWeekdays возвращает дни месяца, начинающегося в first, подходящие под rule
+func monthWeekdays(first time.Time, rule WeekdayRule) []time.Time {
+	var days []time.Time
+	for d := first.AddDate(0, 0, (int(rule.Weekday)-int(first.Weekday())+7)%7); d.Month() == first.Month(); d = d.AddDate(0, 0, 7) {
+		days = append(days, d)
+	}
+	switch {
+	case rule.Ordinal > 0 && rule.Ordinal <= len(days):
+		return days[rule.Ordinal-1 : rule.Ordinal]
+	case rule.Ordinal < 0 && -rule.Ordinal <= len(days):
+		i := len(days) + rule.Ordinal
+		return days[i : i+1]
+	case rule.Ordinal == 0:
+		return days
+	default:
+		return nil
+	}
+}
+
+func sortTimes(times []time.Time) {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+}
+
+func dedupTimes(times []time.Time) []time.Time {
+	result := times[:0]
+	for i, t := range times {
+		if i == 0 || !t.Equal(times[i-1]) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
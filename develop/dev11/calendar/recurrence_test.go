@@ -0,0 +1,125 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{"freq=monthly;byday=-1fr;count=3", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3"},
+		{"FREQ=DAILY;UNTIL=20240630", "FREQ=DAILY;UNTIL=20240630T235959Z"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRRule(tt.in)
+		if err != nil {
+			t.Errorf("ParseRRule(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("ParseRRule(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRRuleInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240630",
+		"FREQ=DAILY;BYHOUR=9",
+	} {
+		_, err := ParseRRule(in)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("ParseRRule(%q): expected ValidationError, got %v", in, err)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rrule string
+		start time.Time
+		from  time.Time
+		to    time.Time
+		want  []time.Time
+	}{
+		{
+			"daily with interval",
+			"FREQ=DAILY;INTERVAL=2",
+			date(2024, 5, 30), date(2024, 6, 1), date(2024, 6, 6),
+			[]time.Time{date(2024, 6, 1), date(2024, 6, 3), date(2024, 6, 5)},
+		},
+		{
+			"weekly by day skips days before start",
+			"FREQ=WEEKLY;BYDAY=MO,TH",
+			date(2024, 5, 30), date(2024, 5, 27), date(2024, 6, 7),
+			[]time.Time{date(2024, 5, 30), date(2024, 6, 3), date(2024, 6, 6)},
+		},
+		{
+			"weekly with count",
+			"FREQ=WEEKLY;COUNT=2",
+			date(2024, 5, 30), date(2024, 5, 1), date(2024, 7, 1),
+			[]time.Time{date(2024, 5, 30), date(2024, 6, 6)},
+		},
+		{
+			"monthly skips missing days",
+			"FREQ=MONTHLY",
+			date(2024, 1, 31), date(2024, 1, 1), date(2024, 5, 1),
+			[]time.Time{date(2024, 1, 31), date(2024, 3, 31)},
+		},
+		{
+			"monthly last friday until",
+			"FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20240731",
+			date(2024, 5, 1), date(2024, 1, 1), date(2025, 1, 1),
+			[]time.Time{date(2024, 5, 31), date(2024, 6, 28), date(2024, 7, 26)},
+		},
+		{
+			"count is taken from the series start",
+			"FREQ=DAILY;COUNT=3",
+			date(2024, 5, 30), date(2024, 6, 1), date(2024, 6, 10),
+			[]time.Time{date(2024, 6, 1)},
+		},
+		{
+			"old daily series far in the future",
+			"FREQ=DAILY;INTERVAL=3",
+			date(1900, 1, 1), date(2199, 12, 30), date(2200, 1, 6),
+			[]time.Time{date(2199, 12, 31), date(2200, 1, 3)},
+		},
+		{
+			"old weekly series far in the future",
+			"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU;UNTIL=22000131",
+			date(1900, 1, 3), date(2200, 1, 1), date(2200, 2, 28),
+			[]time.Time{date(2200, 1, 6), date(2200, 1, 12), date(2200, 1, 20), date(2200, 1, 26)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRRule(tt.rrule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := r.Occurrences(tt.start, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
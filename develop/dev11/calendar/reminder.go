@@ -0,0 +1,58 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+)
+
+// Напоминание можно поставить не раньше чем за четыре недели до события
+const maxReminderMinutes = 4 * 7 * 24 * 60
+
+// Reminder — сработавшее напоминание о вхождении события
+type Reminder struct {
+	EventID int       `json:"event_id"`
+	UserID  int       `json:"user_id"`
+	Title   string    `json:"title"`
+	Start   time.Time `json:"start"`
+	Minutes int       `json:"minutes"`
+	FireAt  time.Time `json:"fire_at"`
+}
+
+// DueReminders возвращает напоминания со временем срабатывания в [from, to),
+// отсортированные по этому времени. Напоминания вычисляются по текущему
+// состоянию событий, поэтому удаление или перенос события их отменяет.
+func (s *Service) DueReminders(from, to time.Time) ([]Reminder, error) {
+	events, err := s.store.List()
+	if err != nil {
+		return nil, internal(err)
+	}
+
+	var result []Reminder
+	for _, event := range events {
+		for _, minutes := range event.Reminders {
+			offset := time.Duration(minutes) * time.Minute
+			start, end := from.Add(offset), to.Add(offset)
+			for _, occurrence := range event.occurrences(start, end) {
+				// событие с длительностью попадает в интервал и когда уже идет
+				if occurrence.Date.Before(start) {
+					continue
+				}
+				result = append(result, Reminder{
+					EventID: event.ID,
+					UserID:  event.UserID,
+					Title:   event.Title,
+					Start:   occurrence.Date,
+					Minutes: minutes,
+					FireAt:  occurrence.Date.Add(-offset),
+				})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].FireAt.Equal(result[j].FireAt) {
+			return result[i].FireAt.Before(result[j].FireAt)
+		}
+		return result[i].EventID < result[j].EventID
+	})
+	return result, nil
+}
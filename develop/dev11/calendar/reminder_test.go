@@ -0,0 +1,73 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDueReminders(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	start := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	meeting, _ := s.CreateEvent(1, EventParams{Date: start, End: &end, Title: "Meeting", Reminders: []int{15, 60}})
+
+	reminders, err := s.DueReminders(start.Add(-time.Hour), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 2 {
+		t.Fatalf("Expected 2 reminders, got %+v", reminders)
+	}
+	if r := reminders[0]; r.Minutes != 60 || !r.FireAt.Equal(start.Add(-time.Hour)) || r.EventID != meeting.ID || r.UserID != 1 {
+		t.Errorf("Unexpected first reminder: %+v", r)
+	}
+	if r := reminders[1]; r.Minutes != 15 || !r.FireAt.Equal(start.Add(-15*time.Minute)) {
+		t.Errorf("Unexpected second reminder: %+v", r)
+	}
+
+	// идущее событие не напоминает о себе повторно
+	if reminders, _ := s.DueReminders(start, end); len(reminders) != 0 {
+		t.Errorf("Expected no reminders during the event, got %+v", reminders)
+	}
+
+	// перенос события переносит напоминания
+	s.UpdateEvent(meeting.ID, 1, EventParams{Date: start.Add(24 * time.Hour), Title: "Meeting", Reminders: []int{15}})
+	if reminders, _ := s.DueReminders(start.Add(-time.Hour), start); len(reminders) != 0 {
+		t.Errorf("Expected moved event to cancel reminders, got %+v", reminders)
+	}
+	if reminders, _ := s.DueReminders(start.Add(23*time.Hour), start.Add(24*time.Hour)); len(reminders) != 1 {
+		t.Errorf("Expected reminder for moved event, got %+v", reminders)
+	}
+
+	s.DeleteEvent(meeting.ID, 1, 0)
+	if reminders, _ := s.DueReminders(start.Add(23*time.Hour), start.Add(24*time.Hour)); len(reminders) != 0 {
+		t.Errorf("Expected deleted event to cancel reminders, got %+v", reminders)
+	}
+}
+
+func TestDueRemindersRecurring(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	start := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	series, _ := s.CreateEvent(1, EventParams{Date: start, Title: "Standup", RRule: rrule, Reminders: []int{10}})
+	s.CancelOccurrence(series.ID, 1, start.AddDate(0, 0, 1), 0)
+
+	reminders, err := s.DueReminders(start.AddDate(0, 0, -1), start.AddDate(0, 0, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 2 || !reminders[1].Start.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("Expected reminders for remaining occurrences, got %+v", reminders)
+	}
+}
+
+func TestRemindersValidation(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	for _, minutes := range []int{-5, maxReminderMinutes + 1} {
+		_, err := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Bad", Reminders: []int{minutes}})
+		if !errors.Is(err, ErrInvalidReminder) {
+			t.Errorf("Reminder %d: expected ErrInvalidReminder, got %v", minutes, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package calendar
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchQuery — параметры поиска событий по названию
+type SearchQuery struct {
+	Text string
+	// Tokens — искать слова запроса целиком в любом порядке,
+	// иначе запрос ищется подстрокой
+	Tokens bool
+	// Необязательный период [From, To); в нем повторения разворачиваются
+	From, To time.Time
+}
+
+// SearchEvents ищет события пользователя по названию без учета регистра.
+// Без периода возвращаются сохраненные события, с периодом — их вхождения.
+func (s *Service) SearchEvents(userID int, query SearchQuery) ([]Event, error) {
+	text := strings.ToLower(strings.TrimSpace(query.Text))
+	if text == "" {
+		return nil, &ValidationError{Msg: "search query is required"}
+	}
+	if !query.From.IsZero() || !query.To.IsZero() {
+		if err := validateRange(query.From, query.To); err != nil {
+			return nil, err
+		}
+	}
+	matches := func(title string) bool {
+		return strings.Contains(strings.ToLower(title), text)
+	}
+	if query.Tokens {
+		tokens := words(text)
+		matches = func(title string) bool {
+			titleWords := make(map[string]bool)
+			for _, word := range words(strings.ToLower(title)) {
+				titleWords[word] = true
+			}
+			for _, token := range tokens {
+				if !titleWords[token] {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	events, err := s.store.List()
+	if err != nil {
+		return nil, internal(err)
+	}
+
+	var result []Event
+	for _, event := range events {
+		if event.UserID != userID || !matches(event.Title) {
+			continue
+		}
+		if query.From.IsZero() || query.To.IsZero() {
+			result = append(result, event)
+			continue
+		}
+		result = append(result, event.occurrences(query.From, query.To)...)
+	}
+	sortEvents(result)
+	return result, nil
+}
+
+// words разбивает текст на слова по всему, что не буква и не цифра
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
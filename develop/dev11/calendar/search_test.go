@@ -0,0 +1,48 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchEvents(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=WEEKLY;COUNT=4")
+	s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Sprint planning", RRule: rrule})
+	s.CreateEvent(1, EventParams{Date: date(2024, 6, 5), Title: "Планёрка команды"})
+	s.CreateEvent(1, EventParams{Date: date(2024, 6, 6), Title: "Planning poker"})
+	s.CreateEvent(2, EventParams{Date: date(2024, 6, 3), Title: "Sprint planning"})
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string
+	}{
+		{"substring ignores case", SearchQuery{Text: "PLAN"}, []string{"Sprint planning", "Planning poker"}},
+		{"cyrillic", SearchQuery{Text: "планёрка"}, []string{"Планёрка команды"}},
+		{"tokens in any order", SearchQuery{Text: "planning sprint", Tokens: true}, []string{"Sprint planning"}},
+		{"tokens match whole words", SearchQuery{Text: "plan", Tokens: true}, nil},
+		{"range expands occurrences", SearchQuery{Text: "sprint", From: date(2024, 6, 1), To: date(2024, 6, 15)}, []string{"Sprint planning", "Sprint planning"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := s.SearchEvents(1, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("Got %+v, want titles %v", events, tt.want)
+			}
+			for i, title := range tt.want {
+				if events[i].Title != title {
+					t.Errorf("events[%d].Title = %q, want %q", i, events[i].Title, title)
+				}
+			}
+		})
+	}
+
+	var validationErr *ValidationError
+	if _, err := s.SearchEvents(1, SearchQuery{Text: "  "}); !errors.As(err, &validationErr) {
+		t.Errorf("Expected ValidationError for empty query, got %v", err)
+	}
+}
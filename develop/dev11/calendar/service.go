@@ -0,0 +1,480 @@
+// Package calendar содержит бизнес-логику календаря и хранилища событий.
+// Пакет не зависит от HTTP и может использоваться из CLI или тестов.
+package calendar
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Service — сервис календаря поверх EventStore
+type Service struct {
+	store   EventStore
+	changes *Hub
+	now     func() time.Time
+
+	// mu сериализует изменения вида "прочитать-изменить-записать",
+	// чтобы конкурентные правки одной серии не теряли друг друга;
+	// им же защищен журнал изменений
+	mu    sync.Mutex
+	audit auditLog
+
+	// maxEvents — наибольшее число событий пользователя, 0 — без ограничения
+	maxEvents int
+}
+
+func NewService(store EventStore) *Service {
+	return &Service{store: store, changes: NewHub(), now: time.Now, audit: auditLog{max: maxAuditRecords}}
+}
+
+// LimitEventsPerUser ограничивает число хранимых событий пользователя,
+// n = 0 снимает ограничение. Вызывается до начала обработки запросов.
+func (s *Service) LimitEventsPerUser(n int) {
+	s.maxEvents = n
+}
+
+// checkEventLimit проверяет, что пользователь может создать еще n событий
+func (s *Service) checkEventLimit(userID, n int) error {
+	if s.maxEvents == 0 || n == 0 {
+		return nil
+	}
+	events, err := s.store.List()
+	if err != nil {
+		return internal(err)
+	}
+	count := 0
+	for _, event := range events {
+		if event.UserID == userID {
+			count++
+		}
+	}
+	if count+n > s.maxEvents {
+		return ErrTooManyEvents
+	}
+	return nil
+}
+
+// Changes возвращает хаб, в который публикуются все изменения событий
+func (s *Service) Changes() *Hub {
+	return s.changes
+}
+
+func (s *Service) CreateEvent(userID int, params EventParams) (Event, error) {
+	if err := params.validate(); err != nil {
+		return Event{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createEvent(s.begin(userID), params)
+}
+
+// createEvent, updateEvent и deleteEvent выполняют изменения в рамках
+// операции op, вызываются под s.mu
+func (s *Service) createEvent(op *operation, params EventParams) (Event, error) {
+	event := Event{UserID: op.actor}
+	params.apply(&event)
+	if err := s.checkConflicts(event, params); err != nil {
+		return Event{}, err
+	}
+	return op.create(event)
+}
+
+// checkConflicts отклоняет пересекающееся событие, если это запрошено в params
+func (s *Service) checkConflicts(event Event, params EventParams) error {
+	if !params.RejectConflicts {
+		return nil
+	}
+	events, err := s.store.List()
+	if err != nil {
+		return internal(err)
+	}
+	return checkOverlaps(event, events)
+}
+
+// UpdateEvent изменяет событие id от имени пользователя userID.
+// Изменять событие может только его владелец.
+func (s *Service) UpdateEvent(id, userID int, params EventParams) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateEvent(s.begin(userID), id, params)
+}
+
+func (s *Service) updateEvent(op *operation, id int, params EventParams) (Event, error) {
+	event, err := s.ownedEvent(id, op.actor)
+	if err != nil {
+		return Event{}, err
+	}
+	if err := params.validate(); err != nil {
+		return Event{}, err
+	}
+
+	if err := checkVersion(event, params.Version); err != nil {
+		return Event{}, err
+	}
+
+	params.apply(&event)
+	if err := s.checkConflicts(event, params); err != nil {
+		return Event{}, err
+	}
+	return op.update(event)
+}
+
+// checkVersion отклоняет изменение, если событие изменилось после того,
+// как клиент прочитал его версию; нулевая версия не проверяется
+func checkVersion(event Event, version int) error {
+	if version != 0 && version != event.Version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// DeleteEvent удаляет событие id от имени пользователя userID, если его
+// версия равна version (0 — без проверки). Для серии удаляются и все ее
+// измененные вхождения.
+func (s *Service) DeleteEvent(id, userID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteEvent(s.begin(userID), id, version)
+}
+
+func (s *Service) deleteEvent(op *operation, id, version int) error {
+	event, err := s.ownedEvent(id, op.actor)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(event, version); err != nil {
+		return err
+	}
+	if err := op.remove(event); err != nil {
+		return err
+	}
+	if event.RRule == nil {
+		return nil
+	}
+
+	events, err := s.store.List()
+	if err != nil {
+		return internal(err)
+	}
+	for _, e := range events {
+		if e.RecurrenceID == id {
+			if err := op.remove(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateOccurrence изменяет одно вхождение серии id, начинающееся в occurrence.
+// Вхождение исключается из серии и сохраняется отдельным событием,
+// которое дальше редактируется как обычное. params.Version сверяется
+// с версией серии.
+func (s *Service) UpdateOccurrence(id, userID int, occurrence time.Time, params EventParams) (Event, error) {
+	if err := params.validate(); err != nil {
+		return Event{}, err
+	}
+	if params.RRule != nil {
+		return Event{}, &ValidationError{Msg: "rrule is not allowed for a single occurrence"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := s.begin(userID)
+
+	series, err := s.seriesOccurrence(id, userID, occurrence)
+	if err != nil {
+		return Event{}, err
+	}
+	if err := checkVersion(series, params.Version); err != nil {
+		return Event{}, err
+	}
+
+	override := Event{
+		UserID:       userID,
+		RecurrenceID: series.ID,
+		OriginalDate: &occurrence,
+		Attendees:    series.Attendees,
+	}
+	params.apply(&override)
+	if err := s.checkConflicts(override, params); err != nil {
+		return Event{}, err
+	}
+	override, err = op.create(override)
+	if err != nil {
+		return Event{}, err
+	}
+
+	series.ExDates = append(series.ExDates, occurrence)
+	if _, err := op.update(series); err != nil {
+		return Event{}, err
+	}
+	return override, nil
+}
+
+// CancelOccurrence отменяет одно вхождение серии id версии version
+func (s *Service) CancelOccurrence(id, userID int, occurrence time.Time, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := s.begin(userID)
+
+	series, err := s.seriesOccurrence(id, userID, occurrence)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(series, version); err != nil {
+		return err
+	}
+	series.ExDates = append(series.ExDates, occurrence)
+	_, err = op.update(series)
+	return err
+}
+
+// seriesOccurrence возвращает серию, если occurrence — ее действующее вхождение
+func (s *Service) seriesOccurrence(id, userID int, occurrence time.Time) (Event, error) {
+	series, err := s.ownedEvent(id, userID)
+	if err != nil {
+		return Event{}, err
+	}
+	if series.RRule == nil {
+		return Event{}, ErrNotRecurring
+	}
+	if !series.RRule.IsOccurrence(series.Date.In(series.location()), occurrence) || series.isExcluded(occurrence) {
+		return Event{}, ErrOccurrenceNotFound
+	}
+	return series, nil
+}
+
+// ownedEvent возвращает событие, если оно принадлежит пользователю userID
+func (s *Service) ownedEvent(id, userID int) (Event, error) {
+	event, err := s.store.Get(id)
+	if err != nil {
+		return Event{}, internal(err)
+	}
+	if event.UserID != userID {
+		return Event{}, ErrNotOwner
+	}
+	return event, nil
+}
+
+func (s *Service) EventsForDay(userID int, date time.Time) ([]Event, error) {
+	return s.eventsBetween(userID, date, date.AddDate(0, 0, 1))
+}
+
+// EventsForWeek возвращает события недели ISO 8601, начинающейся с понедельника
+func (s *Service) EventsForWeek(userID int, date time.Time) ([]Event, error) {
+	return s.EventsForWeekStarting(userID, date, time.Monday)
+}
+
+// EventsForWeekStarting возвращает события недели, начинающейся в день weekStart
+func (s *Service) EventsForWeekStarting(userID int, date time.Time, weekStart time.Weekday) ([]Event, error) {
+	startOfWeek := date.AddDate(0, 0, -(int(date.Weekday())-int(weekStart)+7)%7)
+	return s.eventsBetween(userID, startOfWeek, startOfWeek.AddDate(0, 0, 7))
+}
+
+func (s *Service) EventsForMonth(userID int, date time.Time) ([]Event, error) {
+	startOfMonth := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	return s.eventsBetween(userID, startOfMonth, startOfMonth.AddDate(0, 1, 0))
+}
+
+// EventsInRange возвращает события пользователя, пересекающиеся с [from, to)
+func (s *Service) EventsInRange(userID int, from, to time.Time) ([]Event, error) {
+	if err := validateRange(from, to); err != nil {
+		return nil, err
+	}
+	return s.eventsBetween(userID, from, to)
+}
+
+// eventsBetween возвращает вхождения событий пользователя userID,
+// в том числе тех, куда он приглашен и не отказался, пересекающиеся
+// с [from, to), по порядку начала; повторяющиеся события разворачиваются.
+// Границы периода считаются в зоне date, то есть в зоне запрашивающего.
+func (s *Service) eventsBetween(userID int, from, to time.Time) ([]Event, error) {
+	events, err := s.store.List()
+	if err != nil {
+		return nil, internal(err)
+	}
+
+	var result []Event
+	for _, event := range events {
+		if event.attends(userID) {
+			result = append(result, event.occurrences(from, to)...)
+		}
+	}
+	sortEvents(result)
+	return result, nil
+}
+
+// EventCount возвращает число сохраненных событий всех пользователей
+func (s *Service) EventCount() (int, error) {
+	events, err := s.store.List()
+	if err != nil {
+		return 0, internal(err)
+	}
+	return len(events), nil
+}
+
+// ExportEvents возвращает сохраненные события пользователя без разворачивания
+// повторений: серии вместе с их измененными вхождениями. Если from и to
+// не нулевые, выбираются только события с вхождениями в [from, to).
+func (s *Service) ExportEvents(userID int, from, to time.Time) ([]Event, error) {
+	events, err := s.store.List()
+	if err != nil {
+		return nil, internal(err)
+	}
+
+	var result []Event
+	for _, event := range events {
+		if event.UserID != userID {
+			continue
+		}
+		if !from.IsZero() && !to.IsZero() && len(event.occurrences(from, to)) == 0 {
+			continue
+		}
+		result = append(result, event)
+	}
+	sortEvents(result)
+	return result, nil
+}
+
+// ImportICS импортирует события из iCalendar от имени пользователя userID.
+// События с уже известным UID обновляются с сохранением полей, которых
+// нет в iCalendar (см. mergeICS), остальные создаются; UID
+// вида <id>@httptask из собственного экспорта указывает на событие id.
+// Возвращает число импортированных событий.
+func (s *Service) ImportICS(userID int, r io.Reader) (int, error) {
+	events, err := ReadICS(r)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := s.begin(userID)
+
+	stored, err := s.store.List()
+	if err != nil {
+		return 0, internal(err)
+	}
+	masters := make(map[string]Event)
+	for _, event := range stored {
+		if event.UserID == userID && event.RecurrenceID == 0 {
+			masters[event.icalUID()] = event
+		}
+	}
+	for i, event := range events {
+		if master, ok := masters[event.UID]; ok && event.UID != "" {
+			events[i] = master.localizeICS(event)
+		}
+	}
+
+	// сначала все проверяется, чтобы не импортировать файл наполовину
+	for _, event := range events {
+		if err := event.params().validate(); err != nil {
+			return 0, err
+		}
+		if event.OriginalDate != nil && !hasMaster(events, masters, event.UID) {
+			return 0, &ValidationError{Msg: "invalid ics: RECURRENCE-ID for unknown UID " + event.UID}
+		}
+	}
+	if err := s.checkEventLimit(userID, countNew(events, masters, stored)); err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if event.OriginalDate != nil {
+			continue
+		}
+		event.UserID = userID
+		if existing, ok := masters[event.UID]; ok && event.UID != "" {
+			event, err = op.update(existing.mergeICS(event))
+		} else {
+			event, err = op.create(event)
+		}
+		if err != nil {
+			return 0, err
+		}
+		masters[event.UID] = event
+	}
+
+	for _, event := range events {
+		if event.OriginalDate == nil {
+			continue
+		}
+		if err := s.importOverride(op, userID, masters[event.UID].ID, event, stored); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), nil
+}
+
+func hasMaster(events []Event, masters map[string]Event, uid string) bool {
+	if _, ok := masters[uid]; ok {
+		return true
+	}
+	for _, event := range events {
+		if event.UID == uid && event.OriginalDate == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// countNew возвращает число импортируемых событий, которые будут созданы,
+// а не обновят уже сохраненные
+func countNew(events []Event, masters map[string]Event, stored []Event) int {
+	n := 0
+	for _, event := range events {
+		if event.OriginalDate == nil {
+			if _, ok := masters[event.UID]; !ok || event.UID == "" {
+				n++
+			}
+			continue
+		}
+		master, ok := masters[event.UID]
+		if !ok || !hasOverride(stored, master.ID, *event.OriginalDate) {
+			n++
+		}
+	}
+	return n
+}
+
+func hasOverride(stored []Event, seriesID int, occurrence time.Time) bool {
+	for _, event := range stored {
+		if event.RecurrenceID == seriesID && event.OriginalDate != nil && event.OriginalDate.Equal(occurrence) {
+			return true
+		}
+	}
+	return false
+}
+
+// importOverride сохраняет измененное вхождение серии seriesID,
+// заменяя ранее импортированное изменение того же вхождения
+func (s *Service) importOverride(op *operation, userID, seriesID int, override Event, stored []Event) error {
+	override.UserID = userID
+	override.UID = ""
+	override.RecurrenceID = seriesID
+
+	for _, event := range stored {
+		if event.RecurrenceID == seriesID && event.OriginalDate != nil && event.OriginalDate.Equal(*override.OriginalDate) {
+			_, err := op.update(event.mergeICS(override))
+			return err
+		}
+	}
+	if _, err := op.create(override); err != nil {
+		return err
+	}
+
+	series, err := s.store.Get(seriesID)
+	if err != nil {
+		return internal(err)
+	}
+	if series.isExcluded(*override.OriginalDate) {
+		return nil
+	}
+	series.ExDates = append(series.ExDates, *override.OriginalDate)
+	_, err = op.update(series)
+	return err
+}
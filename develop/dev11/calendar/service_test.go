@@ -0,0 +1,326 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestServiceCreateUpdateDelete(t *testing.T) {
+	s := NewService(NewMemoryStore())
+
+	event, err := s.CreateEvent(1, EventParams{Date: date(2024, 5, 30), Title: "Standup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 6, 1), Title: "Retro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.UserID != 1 || updated.Title != "Retro" || !updated.Date.Equal(date(2024, 6, 1)) {
+		t.Errorf("Unexpected updated event: %+v", updated)
+	}
+
+	if err := s.DeleteEvent(event.ID, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteEvent(event.ID, 1, 0); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+}
+
+func TestServiceRejectsEmptyTitle(t *testing.T) {
+	s := NewService(NewMemoryStore())
+
+	if _, err := s.CreateEvent(1, EventParams{Date: date(2024, 5, 30), Title: ""}); !errors.Is(err, ErrEmptyTitle) {
+		t.Errorf("Expected ErrEmptyTitle, got %v", err)
+	}
+
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 5, 30), Title: "Standup"})
+	if _, err := s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 5, 30), Title: ""}); !errors.Is(err, ErrEmptyTitle) {
+		t.Errorf("Expected ErrEmptyTitle, got %v", err)
+	}
+	if _, err := s.UpdateEvent(42, 1, EventParams{Date: date(2024, 5, 30), Title: "Title"}); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+}
+
+func TestServiceEventsForPeriod(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	s.CreateEvent(1, EventParams{Date: date(2024, 5, 30), Title: "Thursday"})
+	s.CreateEvent(1, EventParams{Date: date(2024, 6, 4), Title: "Next week"})
+	s.CreateEvent(2, EventParams{Date: date(2024, 5, 30), Title: "Other user"})
+
+	tests := []struct {
+		name  string
+		query func(int, time.Time) ([]Event, error)
+		want  int
+	}{
+		{"day", s.EventsForDay, 1},
+		{"week", s.EventsForWeek, 1},
+		{"month", s.EventsForMonth, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := tt.query(1, date(2024, 5, 30))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != tt.want || events[0].Title != "Thursday" {
+				t.Errorf("Unexpected events: %+v", events)
+			}
+		})
+	}
+}
+
+func TestServiceOwnership(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 5, 30), Title: "Standup"})
+
+	if _, err := s.UpdateEvent(event.ID, 2, EventParams{Date: date(2024, 5, 30), Title: "Hijacked"}); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner on update, got %v", err)
+	}
+	if err := s.DeleteEvent(event.ID, 2, 0); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner on delete, got %v", err)
+	}
+
+	events, _ := s.EventsForDay(1, date(2024, 5, 30))
+	if len(events) != 1 || events[0].Title != "Standup" {
+		t.Errorf("Event must stay unchanged, got %+v", events)
+	}
+	if events, _ := s.EventsForDay(2, date(2024, 5, 30)); len(events) != 0 {
+		t.Errorf("Expected no events for other user, got %+v", events)
+	}
+}
+
+func TestServiceRecurringEvents(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=5")
+	series, err := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup", RRule: rrule})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CancelOccurrence(series.ID, 1, date(2024, 6, 4), 0); err != nil {
+		t.Fatal(err)
+	}
+	override, err := s.UpdateOccurrence(series.ID, 1, date(2024, 6, 5), EventParams{Date: date(2024, 6, 5), Title: "Planning"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := s.EventsForMonth(1, date(2024, 6, 1))
+	titles := make(map[string]int)
+	for _, event := range events {
+		titles[event.Title]++
+	}
+	if titles["Standup"] != 3 || titles["Planning"] != 1 {
+		t.Errorf("Unexpected occurrences: %+v", events)
+	}
+
+	if err := s.CancelOccurrence(series.ID, 1, date(2024, 6, 4), 0); !errors.Is(err, ErrOccurrenceNotFound) {
+		t.Errorf("Expected ErrOccurrenceNotFound for cancelled occurrence, got %v", err)
+	}
+	if err := s.CancelOccurrence(series.ID, 1, date(2024, 6, 10), 0); !errors.Is(err, ErrOccurrenceNotFound) {
+		t.Errorf("Expected ErrOccurrenceNotFound past COUNT, got %v", err)
+	}
+	if err := s.CancelOccurrence(override.ID, 1, date(2024, 6, 5), 0); !errors.Is(err, ErrNotRecurring) {
+		t.Errorf("Expected ErrNotRecurring, got %v", err)
+	}
+
+	if err := s.DeleteEvent(series.ID, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := s.EventsForMonth(1, date(2024, 6, 1)); len(events) != 0 {
+		t.Errorf("Expected series and its overrides to be deleted, got %+v", events)
+	}
+}
+
+func TestServiceTimeZones(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("tzdata is not available")
+	}
+
+	// 23:30 по Москве — это 20:30 UTC того же дня
+	start := time.Date(2024, 5, 30, 23, 30, 0, 0, moscow)
+	end := start.Add(time.Hour)
+	if _, err := s.CreateEvent(1, EventParams{Date: start, End: &end, TimeZone: "Europe/Moscow", Title: "Late call"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		day  time.Time
+		want int
+	}{
+		{time.Date(2024, 5, 30, 0, 0, 0, 0, moscow), 1},
+		// событие заканчивается в 00:30 и пересекается со следующим днем
+		{time.Date(2024, 5, 31, 0, 0, 0, 0, moscow), 1},
+		{time.Date(2024, 5, 29, 0, 0, 0, 0, moscow), 0},
+	} {
+		events, _ := s.EventsForDay(1, tt.day)
+		if len(events) != tt.want {
+			t.Errorf("EventsForDay(%v): got %d events, want %d", tt.day, len(events), tt.want)
+		}
+	}
+}
+
+func TestServiceRecurrenceKeepsLocalTime(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("tzdata is not available")
+	}
+
+	rrule, _ := ParseRRule("FREQ=WEEKLY")
+	start := time.Date(2024, 3, 25, 9, 0, 0, 0, berlin).UTC()
+	s.CreateEvent(1, EventParams{Date: start, TimeZone: "Europe/Berlin", Title: "Weekly", RRule: rrule})
+
+	// переход на летнее время между 25 марта и 1 апреля
+	events, _ := s.EventsForMonth(1, time.Date(2024, 4, 1, 0, 0, 0, 0, berlin))
+	if len(events) == 0 {
+		t.Fatal("Expected occurrences in April")
+	}
+	for _, event := range events {
+		if local := event.Date.In(berlin); local.Hour() != 9 {
+			t.Errorf("Expected occurrence at 09:00 local time, got %v", local)
+		}
+	}
+}
+
+func TestServiceRejectsEndBeforeStart(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	end := date(2024, 5, 29)
+	if _, err := s.CreateEvent(1, EventParams{Date: date(2024, 5, 30), End: &end, Title: "Bad"}); !errors.Is(err, ErrEndBeforeStart) {
+		t.Errorf("Expected ErrEndBeforeStart, got %v", err)
+	}
+}
+
+func TestServicePeriodBoundaries(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	// 2 июня 2024 — воскресенье, 3 июня — понедельник
+	for _, day := range []int{2, 3, 9, 10} {
+		s.CreateEvent(1, EventParams{Date: date(2024, 6, day), Title: "Event"})
+	}
+	s.CreateEvent(1, EventParams{Date: date(2024, 7, 1), Title: "Next month"})
+
+	days := func(events []Event) []int {
+		var result []int
+		for _, event := range events {
+			result = append(result, event.Date.Day())
+		}
+		return result
+	}
+	equal := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	tests := []struct {
+		name  string
+		query func() ([]Event, error)
+		want  []int
+	}{
+		{"iso week from monday", func() ([]Event, error) { return s.EventsForWeek(1, date(2024, 6, 3)) }, []int{3, 9}},
+		{"iso week from sunday date", func() ([]Event, error) { return s.EventsForWeek(1, date(2024, 6, 9)) }, []int{3, 9}},
+		{"sunday week start", func() ([]Event, error) { return s.EventsForWeekStarting(1, date(2024, 6, 5), time.Sunday) }, []int{2, 3}},
+		{"month includes first day", func() ([]Event, error) { return s.EventsForMonth(1, date(2024, 6, 15)) }, []int{2, 3, 9, 10}},
+		{"range is half-open", func() ([]Event, error) { return s.EventsInRange(1, date(2024, 6, 3), date(2024, 6, 10)) }, []int{3, 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := tt.query()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := days(events); !equal(got, tt.want) {
+				t.Errorf("Got days %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var validationErr *ValidationError
+	if _, err := s.EventsInRange(1, date(2024, 6, 10), date(2024, 6, 3)); !errors.As(err, &validationErr) {
+		t.Errorf("Expected ValidationError for reversed range, got %v", err)
+	}
+	if _, err := s.EventsInRange(1, date(2024, 1, 1), date(2026, 1, 1)); !errors.As(err, &validationErr) {
+		t.Errorf("Expected ValidationError for too long range, got %v", err)
+	}
+}
+
+func TestServiceVersions(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	if event.Version != 1 {
+		t.Fatalf("Expected version 1 after create, got %d", event.Version)
+	}
+
+	updated, err := s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 6, 3), Title: "Retro", Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", updated.Version)
+	}
+
+	// второй участник правит событие, прочитанное до первого изменения
+	if _, err := s.UpdateEvent(event.ID, 1, EventParams{Date: date(2024, 6, 3), Title: "Planning", Version: 1}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch on stale update, got %v", err)
+	}
+	if err := s.DeleteEvent(event.ID, 1, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch on stale delete, got %v", err)
+	}
+
+	rrule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	series, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Series", RRule: rrule})
+	if err := s.CancelOccurrence(series.ID, 1, date(2024, 6, 4), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelOccurrence(series.ID, 1, date(2024, 6, 5), 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch on stale cancel, got %v", err)
+	}
+
+	if err := s.DeleteEvent(event.ID, 1, 2); err != nil {
+		t.Errorf("Expected delete with current version to succeed, got %v", err)
+	}
+}
+
+func TestServiceEventLimit(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	s.LimitEventsPerUser(2)
+
+	first, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "First"})
+	s.CreateEvent(1, EventParams{Date: date(2024, 6, 4), Title: "Second"})
+	if _, err := s.CreateEvent(1, EventParams{Date: date(2024, 6, 5), Title: "Third"}); !errors.Is(err, ErrTooManyEvents) {
+		t.Errorf("Expected ErrTooManyEvents, got %v", err)
+	}
+	// лимит считается отдельно для каждого пользователя
+	if _, err := s.CreateEvent(2, EventParams{Date: date(2024, 6, 5), Title: "Other"}); err != nil {
+		t.Errorf("Expected other user to create event, got %v", err)
+	}
+
+	// импорт сверх лимита отклоняется целиком
+	vevent := "BEGIN:VEVENT\r\nSUMMARY:Imported\r\nDTSTART:20240606T100000Z\r\nEND:VEVENT\r\n"
+	ics := "BEGIN:VCALENDAR\r\n" + strings.Repeat(vevent, 2) + "END:VCALENDAR\r\n"
+	s.DeleteEvent(first.ID, 1, 0)
+	if _, err := s.ImportICS(1, strings.NewReader(ics)); !errors.Is(err, ErrTooManyEvents) {
+		t.Errorf("Expected ErrTooManyEvents on import, got %v", err)
+	}
+	if events, _ := s.ExportEvents(1, time.Time{}, time.Time{}); len(events) != 1 {
+		t.Errorf("Expected import to be rejected entirely, got %d events", len(events))
+	}
+}
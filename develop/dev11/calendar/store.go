@@ -0,0 +1,386 @@
+package calendar
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// EventStore — интерфейс хранилища событий, от которого зависит Service
+type EventStore interface {
+	// Create присваивает событию новый ID и сохраняет его
+	Create(event Event) (Event, error)
+	// Restore сохраняет удаленное событие под его прежним ID
+	Restore(event Event) error
+	Update(event Event) error
+	Delete(id int) error
+	Get(id int) (Event, error)
+	List() ([]Event, error)
+	Close() error
+}
+
+// Число шардов MemoryStore. События распределяются по шардам по id,
+// так что запросы к разным событиям не конкурируют за одну блокировку.
+const shardCount = 16
+
+type shard struct {
+	mu     sync.RWMutex
+	events map[int]Event
+}
+
+// MemoryStore — хранилище в памяти, данные теряются при перезапуске.
+// Безопасно для конкурентного использования.
+type MemoryStore struct {
+	shards [shardCount]shard
+	lastID atomic.Int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	for i := range s.shards {
+		s.shards[i].events = make(map[int]Event)
+	}
+	return s
+}
+
+func (s *MemoryStore) shard(id int) *shard {
+	return &s.shards[uint(id)%shardCount]
+}
+
+// put сохраняет событие без проверки существования и сдвигает счетчик id
+func (s *MemoryStore) put(event Event) {
+	sh := s.shard(event.ID)
+	sh.mu.Lock()
+	sh.events[event.ID] = event
+	sh.mu.Unlock()
+	s.reserveID(event.ID)
+}
+
+// reserveID гарантирует, что следующий выданный id будет больше id
+func (s *MemoryStore) reserveID(id int) {
+	for {
+		last := s.lastID.Load()
+		if int64(id) <= last || s.lastID.CompareAndSwap(last, int64(id)) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) remove(id int) {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	delete(sh.events, id)
+	sh.mu.Unlock()
+}
+
+func (s *MemoryStore) Create(event Event) (Event, error) {
+	event.ID = int(s.lastID.Add(1))
+	sh := s.shard(event.ID)
+	sh.mu.Lock()
+	sh.events[event.ID] = event
+	sh.mu.Unlock()
+	return event, nil
+}
+
+func (s *MemoryStore) Restore(event Event) error {
+	sh := s.shard(event.ID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, exists := sh.events[event.ID]; exists || int64(event.ID) > s.lastID.Load() {
+		return fmt.Errorf("cannot restore event %d", event.ID)
+	}
+	sh.events[event.ID] = event
+	return nil
+}
+
+func (s *MemoryStore) Update(event Event) error {
+	sh := s.shard(event.ID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, exists := sh.events[event.ID]; !exists {
+		return ErrEventNotFound
+	}
+	sh.events[event.ID] = event
+	return nil
+}
+
+func (s *MemoryStore) Delete(id int) error {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, exists := sh.events[id]; !exists {
+		return ErrEventNotFound
+	}
+	delete(sh.events, id)
+	return nil
+}
+
+func (s *MemoryStore) Get(id int) (Event, error) {
+	sh := s.shard(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	event, exists := sh.events[id]
+	if !exists {
+		return Event{}, ErrEventNotFound
+	}
+	return event, nil
+}
+
+func (s *MemoryStore) List() ([]Event, error) {
+	var result []Event
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		for _, event := range sh.events {
+			result = append(result, event)
+		}
+		sh.mu.RUnlock()
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// Операции в журнале FileStore
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	opSeq    = "seq"
+)
+
+// logRecord — одна строка журнала FileStore
+type logRecord struct {
+	Op     string `json:"op"`
+	Event  *Event `json:"event,omitempty"`
+	ID     int    `json:"id,omitempty"`
+	NextID int    `json:"next_id,omitempty"`
+}
+
+// FileStore — хранилище с append-only JSON журналом на диске.
+// Состояние держится в памяти, каждое изменение дописывается в журнал.
+// При открытии журнал проигрывается и сжимается до снапшота текущих событий.
+// Изменения сериализуются мьютексом, чтобы порядок записей в журнале
+// совпадал с порядком применения в памяти; чтение идет напрямую из памяти.
+type FileStore struct {
+	mu   sync.Mutex
+	mem  *MemoryStore
+	path string
+	file *os.File
+	// w пишет записи в file; тесты подменяют его, чтобы имитировать сбой
+	w io.Writer
+	// size — длина журнала после последней целой записи
+	size int64
+	// err — журнал не удалось вернуть к size после сбоя, запись запрещена
+	err error
+}
+
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{mem: NewMemoryStore(), path: path}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.file = file
+	s.w = file
+	s.size = info.Size()
+	return s, nil
+}
+
+// replay восстанавливает состояние из журнала. Неразборчивая последняя
+// строка — запись, оборванная сбоем, — отбрасывается; повреждение
+// в середине журнала — ошибка.
+func (s *FileStore) replay() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	// torn — ошибка разбора строки; допустима только в последней строке,
+	// которую мог оборвать сбой во время append
+	var torn error
+	for scanner.Scan() {
+		line++
+		if torn != nil {
+			return torn
+		}
+		var rec logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			torn = fmt.Errorf("%s:%d: %w", s.path, line, err)
+			continue
+		}
+		switch rec.Op {
+		case opPut:
+			if rec.Event == nil {
+				return fmt.Errorf("%s:%d: put without event", s.path, line)
+			}
+			s.mem.put(*rec.Event)
+		case opDelete:
+			s.mem.remove(rec.ID)
+		case opSeq:
+			s.mem.reserveID(rec.NextID - 1)
+		default:
+			return fmt.Errorf("%s:%d: unknown op %q", s.path, line, rec.Op)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if torn != nil {
+		// compact после replay перепишет журнал уже без оборванной записи
+		slog.Warn("Dropping torn last record of event log", "error", torn)
+	}
+	return nil
+}
+
+// compact переписывает журнал снапшотом текущего состояния.
+// Запись идет во временный файл, который затем атомарно заменяет журнал.
+func (s *FileStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(tmp)
+	err = enc.Encode(logRecord{Op: opSeq, NextID: int(s.mem.lastID.Load()) + 1})
+	events, _ := s.mem.List()
+	for _, event := range events {
+		if err != nil {
+			break
+		}
+		event := event
+		err = enc.Encode(logRecord{Op: opPut, Event: &event})
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// append дописывает запись в журнал. Если запись не удалась, например
+// кончилось место, журнал обрезается до прежней длины: иначе следующие
+// записи легли бы после оборванной, и журнал не открылся бы снова.
+func (s *FileStore) append(rec logRecord) error {
+	if s.err != nil {
+		return s.err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err = s.w.Write(data); err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		if truncErr := s.file.Truncate(s.size); truncErr != nil {
+			s.err = fmt.Errorf("event log is damaged after failed write: %w", truncErr)
+		}
+		return err
+	}
+	s.size += int64(len(data))
+	return nil
+}
+
+func (s *FileStore) Create(event Event) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.ID = int(s.mem.lastID.Add(1))
+	if err := s.append(logRecord{Op: opPut, Event: &event}); err != nil {
+		return Event{}, err
+	}
+	s.mem.put(event)
+	return event, nil
+}
+
+func (s *FileStore) Restore(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.mem.Get(event.ID); err == nil || int64(event.ID) > s.mem.lastID.Load() {
+		return fmt.Errorf("cannot restore event %d", event.ID)
+	}
+	if err := s.append(logRecord{Op: opPut, Event: &event}); err != nil {
+		return err
+	}
+	s.mem.put(event)
+	return nil
+}
+
+func (s *FileStore) Update(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.mem.Get(event.ID); err != nil {
+		return err
+	}
+	if err := s.append(logRecord{Op: opPut, Event: &event}); err != nil {
+		return err
+	}
+	s.mem.put(event)
+	return nil
+}
+
+func (s *FileStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.mem.Get(id); err != nil {
+		return err
+	}
+	if err := s.append(logRecord{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+	s.mem.remove(id)
+	return nil
+}
+
+func (s *FileStore) Get(id int) (Event, error) {
+	return s.mem.Get(id)
+}
+
+func (s *FileStore) List() ([]Event, error) {
+	return s.mem.List()
+}
+
+// Close сбрасывает журнал на диск и закрывает его
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
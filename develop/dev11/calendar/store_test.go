@@ -0,0 +1,335 @@
+package calendar
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testEvent(title string) Event {
+	return Event{UserID: 1, Date: time.Date(2024, 5, 30, 0, 0, 0, 0, time.UTC), Title: title}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+
+	created, err := s.Create(testEvent("First"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != 1 {
+		t.Errorf("Expected id 1, got %d", created.ID)
+	}
+
+	created.Title = "Updated"
+	if err := s.Update(created); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Updated" {
+		t.Errorf("Expected title %q, got %q", "Updated", got.Title)
+	}
+
+	if err := s.Delete(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(created.ID); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+	if err := s.Update(Event{ID: 42}); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+}
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.Create(testEvent("First"))
+	second, _ := s.Create(testEvent("Second"))
+	third, _ := s.Create(testEvent("Third"))
+	second.Title = "Second updated"
+	if err := s.Update(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(third.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	events, _ := s.List()
+	if len(events) != 1 || events[0].Title != "Second updated" {
+		t.Fatalf("Unexpected events after restart: %+v", events)
+	}
+
+	// удаленные id не должны переиспользоваться
+	fourth, _ := s.Create(testEvent("Fourth"))
+	if fourth.ID != 4 {
+		t.Errorf("Expected id 4, got %d", fourth.ID)
+	}
+}
+
+func TestStoreRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	fileStore, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]EventStore{"memory": NewMemoryStore(), "file": fileStore} {
+		t.Run(name, func(t *testing.T) {
+			event, _ := s.Create(testEvent("Event"))
+			if err := s.Restore(event); err == nil {
+				t.Error("Expected error restoring an existing event")
+			}
+			if err := s.Restore(Event{ID: event.ID + 1}); err == nil {
+				t.Error("Expected error restoring a never issued id")
+			}
+
+			s.Delete(event.ID)
+			if err := s.Restore(event); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := s.Get(event.ID); err != nil || got.Title != "Event" {
+				t.Errorf("Expected restored event, got %+v, %v", got, err)
+			}
+		})
+	}
+
+	// восстановление переживает перезапуск
+	fileStore.Close()
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, err := reopened.Get(1); err != nil {
+		t.Errorf("Expected restored event after restart, got %v", err)
+	}
+}
+
+func TestFileStoreCompactsOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, _ := s.Create(testEvent("Event"))
+	for i := 0; i < 50; i++ {
+		if err := s.Update(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	before, _ := os.Stat(path)
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	after, _ := os.Stat(path)
+
+	if after.Size() >= before.Size() {
+		t.Errorf("Expected log to shrink after compaction: %d -> %d", before.Size(), after.Size())
+	}
+}
+
+func TestFileStoreRejectsCorruptLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	log := "not json\n" + `{"op":"put","event":{"id":1,"user_id":1,"title":"Event"}}` + "\n"
+	if err := os.WriteFile(path, []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenFileStore(path); err == nil {
+		t.Error("Expected error for corrupt log")
+	}
+}
+
+func TestFileStoreDropsTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Create(testEvent("First"))
+	s.Create(testEvent("Second"))
+	s.Close()
+
+	// сбой посреди append оставляет в конце журнала половину записи
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"op":"put","event":{"id":3,"user_id":1,"ti`)
+	file.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("Expected torn record to be dropped, got %v", err)
+	}
+	events, _ := s.List()
+	if len(events) != 2 {
+		t.Errorf("Expected 2 events after restart, got %+v", events)
+	}
+	if event, _ := s.Create(testEvent("Third")); event.ID != 3 {
+		t.Errorf("Expected next id 3, got %d", event.ID)
+	}
+	s.Close()
+
+	// журнал переписан без оборванной записи и открывается снова
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if events, _ := s.List(); len(events) != 3 {
+		t.Errorf("Expected 3 events after second restart, got %+v", events)
+	}
+}
+
+// shortWriter записывает половину данных и сообщает об ошибке, как
+// запись на переполненный диск
+type shortWriter struct {
+	w io.Writer
+}
+
+func (w shortWriter) Write(p []byte) (int, error) {
+	n, _ := w.w.Write(p[:len(p)/2])
+	return n, syscall.ENOSPC
+}
+
+func TestFileStoreRecoversFromFailedAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.Create(testEvent("First"))
+
+	s.w = shortWriter{s.file}
+	if _, err := s.Create(testEvent("Lost")); !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Expected ENOSPC, got %v", err)
+	}
+	if events, _ := s.List(); len(events) != 1 {
+		t.Errorf("Failed write must not change the store, got %+v", events)
+	}
+
+	// после освобождения места журнал пишется дальше без оборванной записи
+	s.w = s.file
+	first.Title = "Renamed"
+	if err := s.Update(first); err != nil {
+		t.Fatal(err)
+	}
+	s.Create(testEvent("Second"))
+	s.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("Expected log to open after failed write, got %v", err)
+	}
+	defer s.Close()
+	events, _ := s.List()
+	if len(events) != 2 || events[0].Title != "Renamed" || events[1].Title != "Second" {
+		t.Errorf("Unexpected events after restart: %+v", events)
+	}
+}
+
+// hammerStore параллельно создает, обновляет, удаляет и читает события.
+// Смысл теста — запуск под go test -race.
+func hammerStore(t *testing.T, s EventStore) {
+	const workers = 8
+	const perWorker = 100
+
+	var wg sync.WaitGroup
+	ids := make(chan int, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				event, err := s.Create(testEvent("Event"))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				event.Title = "Updated"
+				if err := s.Update(event); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.List(); err != nil {
+					t.Error(err)
+				}
+				ids <- event.ID
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("Duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+
+	var deleted atomic.Int64
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range seen {
+				// все воркеры пытаются удалить одни и те же события,
+				// успешно удалить каждое должен ровно один
+				if err := s.Delete(id); err == nil {
+					deleted.Add(1)
+				} else if !errors.Is(err, ErrEventNotFound) {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if deleted.Load() != workers*perWorker {
+		t.Errorf("Expected %d deletions, got %d", workers*perWorker, deleted.Load())
+	}
+	if events, _ := s.List(); len(events) != 0 {
+		t.Errorf("Expected empty store, got %d events", len(events))
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	hammerStore(t, NewMemoryStore())
+}
+
+func TestFileStoreConcurrent(t *testing.T) {
+	s, err := OpenFileStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	hammerStore(t, s)
+}
@@ -0,0 +1,147 @@
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Ограничения полей события
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 4000
+	maxLocationLength    = 200
+	maxCategories        = 10
+)
+
+// Допустимый период дат событий
+var (
+	minEventDate = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxEventDate = time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Categories — допустимые категории событий
+var Categories = []string{"work", "personal", "meeting", "travel", "holiday", "birthday", "health", "other"}
+
+var colorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// rule — правило проверки параметров события: нарушенное правило
+// возвращает ошибку валидации, выполненное — nil
+type rule func(p EventParams) error
+
+// eventRules проверяются при каждом изменении события: создании,
+// изменении, изменении вхождения, пакете и импорте. Первое нарушенное
+// правило отклоняет параметры.
+var eventRules = []rule{
+	required(ErrEmptyTitle, func(p EventParams) string { return p.Title }),
+	maxLength("title", maxTitleLength, func(p EventParams) string { return p.Title }),
+	maxLength("description", maxDescriptionLength, func(p EventParams) string { return p.Description }),
+	maxLength("location", maxLocationLength, func(p EventParams) string { return p.Location }),
+	matches("color", colorRe, func(p EventParams) string { return p.Color }),
+	oneOf("category", Categories, maxCategories, func(p EventParams) []string { return p.Categories }),
+	within("date", minEventDate, maxEventDate, func(p EventParams) *time.Time { return &p.Date }),
+	within("end", minEventDate, maxEventDate, func(p EventParams) *time.Time { return p.End }),
+	func(p EventParams) error {
+		if p.End != nil && !p.End.After(p.Date) {
+			return ErrEndBeforeStart
+		}
+		return nil
+	},
+	func(p EventParams) error {
+		if _, err := loadLocation(p.TimeZone); err != nil {
+			return &ValidationError{Msg: "invalid time zone " + p.TimeZone}
+		}
+		return nil
+	},
+	func(p EventParams) error {
+		if !p.AllDay {
+			return nil
+		}
+		loc, _ := loadLocation(p.TimeZone)
+		if !isMidnight(p.Date, loc) || (p.End != nil && !isMidnight(*p.End, loc)) {
+			return ErrAllDayTime
+		}
+		return nil
+	},
+	func(p EventParams) error {
+		for _, minutes := range p.Reminders {
+			if minutes < 0 || minutes > maxReminderMinutes {
+				return ErrInvalidReminder
+			}
+		}
+		return nil
+	},
+}
+
+// validate проверяет параметры правилами eventRules
+func (p EventParams) validate() error {
+	for _, check := range eventRules {
+		if err := check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func required(err error, field func(EventParams) string) rule {
+	return func(p EventParams) error {
+		if field(p) == "" {
+			return err
+		}
+		return nil
+	}
+}
+
+// maxLength ограничивает длину поля в символах
+func maxLength(name string, n int, field func(EventParams) string) rule {
+	return func(p EventParams) error {
+		if utf8.RuneCountInString(field(p)) > n {
+			return &ValidationError{Msg: fmt.Sprintf("%s must be at most %d characters", name, n)}
+		}
+		return nil
+	}
+}
+
+// matches проверяет непустое поле регулярным выражением
+func matches(name string, re *regexp.Regexp, field func(EventParams) string) rule {
+	return func(p EventParams) error {
+		if value := field(p); value != "" && !re.MatchString(value) {
+			return &ValidationError{Msg: "invalid " + name}
+		}
+		return nil
+	}
+}
+
+// oneOf допускает не больше n значений из allowed
+func oneOf(name string, allowed []string, n int, field func(EventParams) []string) rule {
+	return func(p EventParams) error {
+		values := field(p)
+		if len(values) > n {
+			return &ValidationError{Msg: fmt.Sprintf("at most %d %s values are allowed", n, name)}
+		}
+		for _, value := range values {
+			if !slices.Contains(allowed, value) {
+				return &ValidationError{Msg: fmt.Sprintf("%s must be one of %s", name, strings.Join(allowed, ", "))}
+			}
+		}
+		return nil
+	}
+}
+
+// within ограничивает момент времени периодом [from, to); nil не проверяется
+func within(name string, from, to time.Time, field func(EventParams) *time.Time) rule {
+	return func(p EventParams) error {
+		if t := field(p); t != nil && (t.Before(from) || !t.Before(to)) {
+			return &ValidationError{Msg: fmt.Sprintf("%s must be between %s and %s", name, from.Format(time.DateOnly), to.Format(time.DateOnly))}
+		}
+		return nil
+	}
+}
+
+func isMidnight(t time.Time, loc *time.Location) bool {
+	h, m, s := t.In(loc).Clock()
+	return h == 0 && m == 0 && s == 0 && t.Nanosecond() == 0
+}
@@ -0,0 +1,71 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventParamsValidate(t *testing.T) {
+	valid := EventParams{
+		Date:        date(2024, 6, 3),
+		Title:       "Offsite",
+		Description: "Quarterly planning",
+		Location:    "Room 1",
+		Color:       "#3366FF",
+		Categories:  []string{"work", "meeting"},
+		AllDay:      true,
+	}
+	if err := valid.validate(); err != nil {
+		t.Fatalf("Expected valid params, got %v", err)
+	}
+
+	end := date(2024, 6, 3).Add(90 * time.Minute)
+	tests := []struct {
+		name   string
+		change func(p *EventParams)
+	}{
+		{"long title", func(p *EventParams) { p.Title = strings.Repeat("я", maxTitleLength+1) }},
+		{"long description", func(p *EventParams) { p.Description = strings.Repeat("a", maxDescriptionLength+1) }},
+		{"long location", func(p *EventParams) { p.Location = strings.Repeat("a", maxLocationLength+1) }},
+		{"invalid color", func(p *EventParams) { p.Color = "blue" }},
+		{"unknown category", func(p *EventParams) { p.Categories = []string{"work", "party"} }},
+		{"too many categories", func(p *EventParams) { p.Categories = strings.Split(strings.Repeat("work,", maxCategories+1), ",") }},
+		{"date too early", func(p *EventParams) { p.Date = date(1899, 12, 31) }},
+		{"date too late", func(p *EventParams) { p.Date = date(2200, 1, 1) }},
+		{"all-day with time", func(p *EventParams) { p.Date = date(2024, 6, 3).Add(time.Hour) }},
+		{"all-day with partial day", func(p *EventParams) { p.End = &end }},
+	}
+	for _, tt := range tests {
+		p := valid
+		tt.change(&p)
+		var validationErr *ValidationError
+		if err := p.validate(); !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected validation error, got %v", tt.name, err)
+		}
+	}
+
+	// длина считается в символах, а не байтах
+	p := valid
+	p.Title = strings.Repeat("я", maxTitleLength)
+	if err := p.validate(); err != nil {
+		t.Errorf("Expected title of %d runes to be valid, got %v", maxTitleLength, err)
+	}
+}
+
+func TestValidationSharedByCreateAndUpdate(t *testing.T) {
+	s := NewService(NewMemoryStore())
+	event, _ := s.CreateEvent(1, EventParams{Date: date(2024, 6, 3), Title: "Standup"})
+	invalid := EventParams{Date: date(2024, 6, 3), Title: "Standup", Categories: []string{"party"}}
+
+	if _, err := s.CreateEvent(1, invalid); err == nil {
+		t.Error("Expected create to be rejected")
+	}
+	if _, err := s.UpdateEvent(event.ID, 1, invalid); err == nil {
+		t.Error("Expected update to be rejected")
+	}
+	if _, err := s.ApplyBatch(1, []BatchOp{{Action: BatchUpdate, ID: event.ID, Params: invalid}}); err == nil {
+		t.Error("Expected batch update to be rejected")
+	}
+}
@@ -0,0 +1,281 @@
+// Package client — типизированный клиент HTTP API календаря, описанного
+// в /openapi.json. Параметры отправляются формой, ответы {"error": "..."}
+// возвращаются как *APIError.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"httptask/calendar"
+)
+
+// Client обращается к серверу календаря по адресу BaseURL
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Token — bearer токен, если сервер требует аутентификацию
+	Token string
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// APIError — ошибка, которую вернул сервер
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter — через сколько повторить запрос, для ответа 429
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("calendar API: %d: %s", e.StatusCode, e.Message)
+}
+
+// EventParams — параметры создания и изменения события
+type EventParams struct {
+	// Start — начало события. Дата и время суток передаются в его зоне,
+	// зона отличная от UTC — как tz, поэтому она должна быть IANA зоной,
+	// а не time.Local. Время 00:00 не передается: событие в полночь.
+	Start time.Time
+	// Duration — длительность, 0 — событие без длительности
+	Duration time.Duration
+	Title    string
+
+	Description string
+	Location    string
+	// Color — цвет вида #RRGGBB
+	Color string
+	// Categories — категории из calendar.Categories
+	Categories []string
+	// AllDay — событие на весь день: Start в полночь, Duration в целых днях
+	AllDay bool
+
+	// RRule — правило повторения RFC 5545, например FREQ=WEEKLY;COUNT=10
+	RRule string
+	// Reminders — за сколько минут до начала напомнить
+	Reminders []int
+	// RejectConflicts — отклонить событие, пересекающееся с другими
+	RejectConflicts bool
+	// Version — ожидаемая версия изменяемого события, 0 — без проверки
+	Version int
+}
+
+func (p EventParams) form(userID int) url.Values {
+	form := url.Values{
+		"user_id": {strconv.Itoa(userID)},
+		"date":    {p.Start.Format("2006-01-02")},
+		"title":   {p.Title},
+	}
+	if p.Start.Location() != time.UTC {
+		form.Set("tz", p.Start.Location().String())
+	}
+	if h, m, s := p.Start.Clock(); h != 0 || m != 0 || s != 0 {
+		form.Set("start_time", p.Start.Format("15:04"))
+	}
+	if p.Duration != 0 {
+		form.Set("duration", p.Duration.String())
+	}
+	if p.Description != "" {
+		form.Set("description", p.Description)
+	}
+	if p.Location != "" {
+		form.Set("location", p.Location)
+	}
+	if p.Color != "" {
+		form.Set("color", p.Color)
+	}
+	if len(p.Categories) > 0 {
+		form.Set("categories", strings.Join(p.Categories, ","))
+	}
+	if p.AllDay {
+		form.Set("all_day", "true")
+	}
+	if p.RRule != "" {
+		form.Set("rrule", p.RRule)
+	}
+	if len(p.Reminders) > 0 {
+		form.Set("reminders", joinInts(p.Reminders))
+	}
+	if p.RejectConflicts {
+		form.Set("reject_conflicts", "true")
+	}
+	if p.Version != 0 {
+		form.Set("version", strconv.Itoa(p.Version))
+	}
+	return form
+}
+
+// CreateEvent создает событие пользователя userID и возвращает его id и версию
+func (c *Client) CreateEvent(ctx context.Context, userID int, params EventParams) (int, int, error) {
+	resp, err := c.post(ctx, "/create_event", params.form(userID))
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := strconv.Atoi(resp.Header.Get("X-Event-ID"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("calendar API: response without event id")
+	}
+	return id, etagVersion(resp), nil
+}
+
+// UpdateEvent изменяет событие id и возвращает его новую версию
+func (c *Client) UpdateEvent(ctx context.Context, userID, id int, params EventParams) (int, error) {
+	form := params.form(userID)
+	form.Set("id", strconv.Itoa(id))
+	resp, err := c.post(ctx, "/update_event", form)
+	if err != nil {
+		return 0, err
+	}
+	return etagVersion(resp), nil
+}
+
+// DeleteEvent удаляет событие id версии version, 0 — без проверки версии
+func (c *Client) DeleteEvent(ctx context.Context, userID, id, version int) error {
+	form := url.Values{"user_id": {strconv.Itoa(userID)}, "id": {strconv.Itoa(id)}}
+	if version != 0 {
+		form.Set("version", strconv.Itoa(version))
+	}
+	_, err := c.post(ctx, "/delete_event", form)
+	return err
+}
+
+// InviteAttendees приглашает пользователей userIDs на событие id
+// и возвращает его новую версию
+func (c *Client) InviteAttendees(ctx context.Context, userID, id int, userIDs []int, version int) (int, error) {
+	form := url.Values{
+		"user_id":   {strconv.Itoa(userID)},
+		"id":        {strconv.Itoa(id)},
+		"attendees": {joinInts(userIDs)},
+	}
+	if version != 0 {
+		form.Set("version", strconv.Itoa(version))
+	}
+	resp, err := c.post(ctx, "/invite_attendees", form)
+	if err != nil {
+		return 0, err
+	}
+	return etagVersion(resp), nil
+}
+
+// RespondToInvite отвечает на приглашение на событие id от имени userID
+func (c *Client) RespondToInvite(ctx context.Context, userID, id int, status calendar.RSVP) error {
+	form := url.Values{
+		"user_id": {strconv.Itoa(userID)},
+		"id":      {strconv.Itoa(id)},
+		"status":  {string(status)},
+	}
+	_, err := c.post(ctx, "/respond_to_invite", form)
+	return err
+}
+
+// EventsForDay, EventsForWeek и EventsForMonth возвращают события периода,
+// содержащего date; границы периода считаются в зоне date
+func (c *Client) EventsForDay(ctx context.Context, userID int, date time.Time) ([]calendar.Event, error) {
+	return c.events(ctx, "/events_for_day", userID, date)
+}
+
+func (c *Client) EventsForWeek(ctx context.Context, userID int, date time.Time) ([]calendar.Event, error) {
+	return c.events(ctx, "/events_for_week", userID, date)
+}
+
+func (c *Client) EventsForMonth(ctx context.Context, userID int, date time.Time) ([]calendar.Event, error) {
+	return c.events(ctx, "/events_for_month", userID, date)
+}
+
+// events читает все страницы выборки, следуя за X-Next-Cursor
+func (c *Client) events(ctx context.Context, path string, userID int, date time.Time) ([]calendar.Event, error) {
+	query := url.Values{
+		"user_id": {strconv.Itoa(userID)},
+		"date":    {date.Format("2006-01-02")},
+	}
+	if date.Location() != time.UTC {
+		query.Set("tz", date.Location().String())
+	}
+
+	var result []calendar.Event
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page []calendar.Event
+		resp, err := c.do(req, &page)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+
+		cursor := resp.Header.Get("X-Next-Cursor")
+		if cursor == "" {
+			return result, nil
+		}
+		query.Set("cursor", cursor)
+	}
+}
+
+func (c *Client) post(ctx context.Context, path string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var result struct {
+		Result string `json:"result"`
+	}
+	return c.do(req, &result)
+}
+
+// do выполняет запрос и разбирает тело успешного ответа в v
+func (c *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body) == nil && body.Error != "" {
+			apiErr.Message = body.Error
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, apiErr
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("calendar API: decoding response: %w", err)
+	}
+	return resp, nil
+}
+
+// joinInts записывает список чисел через запятую
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// etagVersion возвращает версию события из ETag, 0 — заголовка нет
+func etagVersion(resp *http.Response) int {
+	version, _ := strconv.Atoi(strings.Trim(resp.Header.Get("ETag"), `"`))
+	return version
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCreateEventForm(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/create_event" || r.Method != http.MethodPost {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		r.ParseForm()
+		expected := map[string]string{
+			"user_id":          "7",
+			"date":             "2024-06-03",
+			"start_time":       "10:30",
+			"tz":               "Europe/Moscow",
+			"duration":         "1h0m0s",
+			"title":            "Standup",
+			"location":         "Room 1",
+			"color":            "#3366ff",
+			"categories":       "work,meeting",
+			"rrule":            "FREQ=DAILY;COUNT=5",
+			"reminders":        "5,15",
+			"reject_conflicts": "true",
+		}
+		for key, value := range expected {
+			if got := r.PostForm.Get(key); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+		if _, ok := r.PostForm["version"]; ok {
+			t.Error("Expected no version for zero Version")
+		}
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("X-Event-ID", "42")
+		w.Write([]byte(`{"result":"Event created"}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL + "/")
+	c.Token = "secret"
+	id, version, err := c.CreateEvent(context.Background(), 7, EventParams{
+		Start:           time.Date(2024, 6, 3, 10, 30, 0, 0, moscow),
+		Duration:        time.Hour,
+		Title:           "Standup",
+		Location:        "Room 1",
+		Color:           "#3366ff",
+		Categories:      []string{"work", "meeting"},
+		RRule:           "FREQ=DAILY;COUNT=5",
+		Reminders:       []int{5, 15},
+		RejectConflicts: true,
+	})
+	if err != nil || id != 42 || version != 1 {
+		t.Errorf("CreateEvent = %d, %d, %v; want 42, 1", id, version, err)
+	}
+}
+
+func TestEventsFollowsCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events_for_week" || r.URL.Query().Get("date") != "2024-06-03" || r.URL.Query().Has("tz") {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Header().Set("X-Next-Cursor", "next")
+			w.Write([]byte(`[{"id":1,"user_id":1,"date":"2024-06-03T00:00:00Z","title":"First","version":1}]`))
+		case "next":
+			w.Write([]byte(`[{"id":2,"user_id":1,"date":"2024-06-04T00:00:00Z","title":"Second","version":3}]`))
+		}
+	}))
+	defer server.Close()
+
+	events, err := New(server.URL).EventsForWeek(context.Background(), 1, time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Title != "First" || events[1].Version != 3 {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/delete_event":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"event not found"}`))
+		default:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"rate limit exceeded"}`))
+		}
+	}))
+	defer server.Close()
+	c := New(server.URL)
+
+	err := c.DeleteEvent(context.Background(), 1, 42, 0)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "event not found" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	_, err = c.EventsForDay(context.Background(), 1, time.Now())
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 3*time.Second {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
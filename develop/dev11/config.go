@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"httptask/calendar"
+)
+
+// Префикс переменных окружения: флаг read-timeout читается из CALENDAR_READ_TIMEOUT
+const envPrefix = "CALENDAR_"
+
+// Минимальная длина секрета для подписи токенов
+const minSecretLength = 32
+
+type Config struct {
+	Port            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	Storage         string
+	DataFile        string
+	LogFormat       string
+
+	// Доставка напоминаний: log, webhook или sse
+	Notifier         string
+	WebhookURL       string
+	ReminderInterval time.Duration
+
+	// Аутентификация: none, static или hmac
+	Auth       string
+	TokenFile  string
+	AuthSecret string
+	// IssueToken — выдать hmac токен этому пользователю и завершиться
+	IssueToken int
+	TokenTTL   time.Duration
+
+	// Запросов в секунду на клиента, 0 — без ограничения
+	RateLimit        float64
+	RateBurst        int
+	MaxEventsPerUser int
+}
+
+// loadConfig собирает конфиг из значений по умолчанию, JSON файла из -config
+// (или CALENDAR_CONFIG), переменных окружения и флагов командной строки.
+// Каждый следующий источник переопределяет предыдущий.
+func loadConfig(args []string) (Config, error) {
+	config := Config{}
+	fs := flag.NewFlagSet("calendar", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to JSON config file")
+	fs.StringVar(&config.Port, "port", "8080", "HTTP port")
+	fs.DurationVar(&config.ReadTimeout, "read-timeout", 5*time.Second, "HTTP read timeout")
+	fs.DurationVar(&config.WriteTimeout, "write-timeout", 10*time.Second, "HTTP write timeout")
+	fs.DurationVar(&config.IdleTimeout, "idle-timeout", 60*time.Second, "HTTP keep-alive idle timeout")
+	fs.DurationVar(&config.ShutdownTimeout, "shutdown-timeout", 15*time.Second, "Graceful shutdown timeout")
+	fs.StringVar(&config.Storage, "storage", "memory", "Event storage: memory or file")
+	fs.StringVar(&config.DataFile, "data", "events.log", "Event log path for file storage")
+	fs.StringVar(&config.LogFormat, "log-format", "text", "Log format: text or json")
+	fs.StringVar(&config.Notifier, "notifier", "log", "Reminder delivery: log, webhook or sse")
+	fs.StringVar(&config.WebhookURL, "webhook-url", "", "URL receiving reminders as JSON POST requests")
+	fs.DurationVar(&config.ReminderInterval, "reminder-interval", 30*time.Second, "How often due reminders are checked")
+	fs.StringVar(&config.Auth, "auth", "none", "Authentication: none, static or hmac")
+	fs.StringVar(&config.TokenFile, "token-file", "", "JSON file mapping static bearer tokens to user ids")
+	fs.StringVar(&config.AuthSecret, "auth-secret", "", "Secret for signing hmac tokens")
+	fs.IntVar(&config.IssueToken, "issue-token", 0, "Print an hmac token for this user id and exit")
+	fs.DurationVar(&config.TokenTTL, "token-ttl", 24*time.Hour, "Lifetime of tokens printed by -issue-token")
+	fs.Float64Var(&config.RateLimit, "rate-limit", 10, "Requests per second per client, 0 disables limiting")
+	fs.IntVar(&config.RateBurst, "rate-burst", 20, "Requests a client may burst above the rate limit")
+	fs.IntVar(&config.MaxEventsPerUser, "max-events-per-user", 10000, "Maximum stored events per user, 0 for unlimited")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	path := *configPath
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		for name, value := range values {
+			if fs.Lookup(name) == nil || name == "config" {
+				return Config{}, fmt.Errorf("%s: unknown option %q", path, name)
+			}
+			if explicit[name] {
+				continue
+			}
+			if err := fs.Set(name, value); err != nil {
+				return Config{}, fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(env)
+		if !ok || explicit[f.Name] || f.Name == "config" || err != nil {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: %w", env, setErr)
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, config.validate()
+}
+
+// readConfigFile читает JSON объект с опциями. Ключи совпадают с именами
+// флагов, подчеркивания допускаются вместо дефисов: {"read_timeout": "5s"}
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[strings.ReplaceAll(key, "_", "-")] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+func (c Config) validate() error {
+	if c.Port == "" {
+		return fmt.Errorf("port is required")
+	}
+	if c.Storage != "memory" && c.Storage != "file" {
+		return fmt.Errorf("unknown storage %q", c.Storage)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("unknown log format %q", c.LogFormat)
+	}
+	switch c.Notifier {
+	case "log", "sse":
+	case "webhook":
+		u, err := url.Parse(c.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook url %q", c.WebhookURL)
+		}
+	default:
+		return fmt.Errorf("unknown notifier %q", c.Notifier)
+	}
+	if c.ReminderInterval <= 0 {
+		return fmt.Errorf("reminder interval must be positive")
+	}
+	switch c.Auth {
+	case "none":
+	case "static":
+		if c.TokenFile == "" {
+			return fmt.Errorf("static auth requires a token file")
+		}
+	case "hmac":
+	default:
+		return fmt.Errorf("unknown auth %q", c.Auth)
+	}
+	if (c.Auth == "hmac" || c.IssueToken != 0) && len(c.AuthSecret) < minSecretLength {
+		return fmt.Errorf("auth secret must be at least %d bytes", minSecretLength)
+	}
+	if c.RateLimit < 0 || (c.RateLimit > 0 && c.RateBurst < 1) {
+		return fmt.Errorf("invalid rate limit %v with burst %d", c.RateLimit, c.RateBurst)
+	}
+	if c.MaxEventsPerUser < 0 {
+		return fmt.Errorf("max events per user must not be negative")
+	}
+	return nil
+}
+
+func openStore(config Config) (calendar.EventStore, error) {
+	switch config.Storage {
+	case "memory":
+		return calendar.NewMemoryStore(), nil
+	case "file":
+		return calendar.OpenFileStore(config.DataFile)
+	default:
+		return nil, fmt.Errorf("unknown storage %q", config.Storage)
+	}
+}
+
+// setupLogging настраивает формат логов. Вывод пакета log тоже
+// проходит через slog, так что формат общий для всех сообщений.
+func setupLogging(format string, w io.Writer) {
+	var handler slog.Handler = slog.NewTextHandler(w, nil)
+	if format == "json" {
+		handler = slog.NewJSONHandler(w, nil)
+	}
+	slog.SetDefault(slog.New(handler))
+	log.SetFlags(0)
+}
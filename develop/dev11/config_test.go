@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"httptask/calendar"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	config, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Port != "8080" || config.Storage != "memory" || config.ReadTimeout != 5*time.Second {
+		t.Errorf("Unexpected defaults: %+v", config)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"port": 9000, "read_timeout": "1s", "write_timeout": "2s", "storage": "file"}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CALENDAR_CONFIG", path)
+	t.Setenv("CALENDAR_WRITE_TIMEOUT", "3s")
+	t.Setenv("CALENDAR_READ_TIMEOUT", "4s")
+
+	config, err := loadConfig([]string{"-read-timeout=7s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// флаг > окружение > файл > значение по умолчанию
+	if config.Port != "9000" {
+		t.Errorf("Port = %q, want value from file", config.Port)
+	}
+	if config.WriteTimeout != 3*time.Second {
+		t.Errorf("WriteTimeout = %v, want value from env", config.WriteTimeout)
+	}
+	if config.ReadTimeout != 7*time.Second {
+		t.Errorf("ReadTimeout = %v, want value from flag", config.ReadTimeout)
+	}
+	if config.Storage != "file" || config.LogFormat != "text" {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"prot": "9000"}`), 0644)
+
+	for _, args := range [][]string{
+		{"-storage=redis"},
+		{"-read-timeout=soon"},
+		{"-log-format=xml"},
+		{"-notifier=email"},
+		{"-notifier=webhook"},
+		{"-notifier=webhook", "-webhook-url=ftp://localhost/hook"},
+		{"-reminder-interval=0s"},
+		{"-auth=ldap"},
+		{"-auth=static"},
+		{"-auth=hmac", "-auth-secret=short"},
+		{"-issue-token=1"},
+		{"-rate-limit=-1"},
+		{"-rate-burst=0"},
+		{"-max-events-per-user=-5"},
+		{"-config=" + path},
+	} {
+		if _, err := loadConfig(args); err == nil {
+			t.Errorf("loadConfig(%v): expected error", args)
+		}
+	}
+}
+
+func TestRunGracefulShutdown(t *testing.T) {
+	defer resetEvents()
+	// run включает ограничение частоты, остальным тестам оно мешает
+	t.Cleanup(func() { limiter = nil })
+
+	dataFile := filepath.Join(t.TempDir(), "events.log")
+	config, err := loadConfig([]string{"-storage=file", "-data=" + dataFile, "-shutdown-timeout=5s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, config, listener) }()
+
+	form := url.Values{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"Persisted"}}
+	resp, err := http.PostForm("http://"+listener.Addr().String()+"/create_event", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create returned %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not shut down")
+	}
+
+	// после остановки событие должно читаться из журнала
+	store, err := calendar.OpenFileStore(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	events, _ := store.List()
+	if len(events) != 1 || events[0].Title != "Persisted" {
+		t.Errorf("Unexpected events after restart: %+v", events)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// eventHistoryHandler отдает журнал изменений события id
+func eventHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := parseIntParam(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	records, err := cal.EventHistory(eventID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": records})
+}
+
+// undoHandler отменяет последнюю операцию пользователя
+func undoHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	n, err := cal.Undo(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"result": fmt.Sprintf("Undone %d changes", n)})
+}
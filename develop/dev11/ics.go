@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"httptask/calendar"
+)
+
+// Максимальный размер импортируемого .ics файла
+const maxICSSize = 10 << 20
+
+// exportICSHandler отдает события пользователя в формате iCalendar.
+// Необязательные from и to ограничивают выгрузку периодом [from, to).
+func exportICSHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	loc, err := parseLocation(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	from, to, err := parseRangeParams(r, loc)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	events, err := cal.ExportEvents(userID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="calendar-%d.ics"`, userID))
+	if err := calendar.WriteICS(w, events); err != nil {
+		log.Printf("Error writing iCalendar response: %v", err)
+	}
+}
+
+// importICSHandler импортирует .ics файл, переданный телом запроса
+// или полем file формы multipart/form-data; user_id передается в query
+func importICSHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxICSSize)
+
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var tooLarge *http.MaxBytesError
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if errors.As(err, &tooLarge) {
+			writeError(w, errBodyTooLarge)
+			return
+		}
+		if err != nil {
+			writeError(w, &calendar.ValidationError{Msg: "file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	n, err := cal.ImportICS(userID, body)
+	if errors.As(err, &tooLarge) {
+		writeError(w, errBodyTooLarge)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"result": fmt.Sprintf("Imported %d events", n)})
+}
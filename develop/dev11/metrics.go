@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"httptask/calendar"
+)
+
+// Границы корзин гистограммы длительности запросов, в секундах
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	route  string
+	status int
+}
+
+type histogram struct {
+	counts []uint64 // counts[i] — число наблюдений <= durationBuckets[i]
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range durationBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// metrics — счетчики сервера в формате Prometheus. Без сторонних
+// библиотек: формат текстовый и описывается несколькими строками кода.
+type metrics struct {
+	mu             sync.Mutex
+	requests       map[requestKey]uint64
+	durations      map[requestKey]*histogram
+	errors         map[string]uint64
+	businessErrors map[string]uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:       make(map[requestKey]uint64),
+		durations:      make(map[requestKey]*histogram),
+		errors:         make(map[string]uint64),
+		businessErrors: make(map[string]uint64),
+	}
+}
+
+var serverMetrics = newMetrics()
+
+func (m *metrics) observe(route string, rec *statusRecorder, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := requestKey{route: route, status: rec.status}
+	m.requests[key]++
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[key] = h
+	}
+	h.observe(duration.Seconds())
+
+	if rec.errorClass != "" {
+		m.errors[rec.errorClass]++
+	}
+	// метка — сообщение самой ошибки бизнес-логики без контекста,
+	// например номера операции пакета, чтобы число серий было ограничено
+	var businessErr *calendar.BusinessError
+	if rec.errorClass == errorClassBusiness && errors.As(rec.err, &businessErr) {
+		m.businessErrors[businessErr.Error()]++
+	}
+}
+
+// metricsMiddleware считает запросы к next и их длительность по маршрутам mux.
+// Пути без маршрута учитываются как "other", чтобы число серий было ограничено.
+func metricsMiddleware(m *metrics, mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newRecorder(w)
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		route := "other"
+		if _, pattern := mux.Handler(r); pattern != "" {
+			route = pattern
+		}
+		m.observe(route, rec, time.Since(start))
+	})
+}
+
+// metricsHandler отдает метрики в текстовом формате Prometheus
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	events, err := cal.EventCount()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	serverMetrics.write(w, events)
+}
+
+func (m *metrics) write(w io.Writer, events int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	fmt.Fprintln(w, "# HELP http_requests_total Total number of HTTP requests by route and status code.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "http_requests_total{route=%q,status=\"%d\"} %d\n", key.route, key.status, m.requests[key])
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds HTTP request latency by route and status code.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, key := range keys {
+		h := m.durations[key]
+		labels := fmt.Sprintf("route=%q,status=\"%d\"", key.route, key.status)
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP http_errors_total Total number of error responses by error class.")
+	fmt.Fprintln(w, "# TYPE http_errors_total counter")
+	for _, class := range sortedKeys(m.errors) {
+		fmt.Fprintf(w, "http_errors_total{class=%q} %d\n", class, m.errors[class])
+	}
+
+	fmt.Fprintln(w, "# HELP calendar_business_errors_total Total number of business rule errors by error.")
+	fmt.Fprintln(w, "# TYPE calendar_business_errors_total counter")
+	for _, msg := range sortedKeys(m.businessErrors) {
+		fmt.Fprintf(w, "calendar_business_errors_total{error=%q} %d\n", msg, m.businessErrors[msg])
+	}
+
+	fmt.Fprintln(w, "# HELP calendar_events Number of events in the event store.")
+	fmt.Fprintln(w, "# TYPE calendar_events gauge")
+	fmt.Fprintf(w, "calendar_events %d\n", events)
+
+	fmt.Fprintln(w, "# HELP go_goroutines Number of goroutines that currently exist.")
+	fmt.Fprintln(w, "# TYPE go_goroutines gauge")
+	fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	resetEvents()
+	serverMetrics = newMetrics()
+	captureLogs(t)
+
+	requests := []*http.Request{
+		httptest.NewRequest("POST", "/create_event?user_id=1&date=2024-05-30&title=Standup", nil),
+		httptest.NewRequest("POST", "/create_event?user_id=1&date=2024-05-30", nil),
+		httptest.NewRequest("POST", "/delete_event?user_id=1&id=42", nil),
+		httptest.NewRequest("GET", "/unknown", nil),
+	}
+	for _, req := range requests {
+		serveRouter(req)
+	}
+
+	rr := serveRouter(httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+
+	body := rr.Body.String()
+	for _, line := range []string{
+		`http_requests_total{route="/create_event",status="200"} 1`,
+		`http_requests_total{route="/create_event",status="400"} 1`,
+		`http_requests_total{route="/delete_event",status="503"} 1`,
+		`http_requests_total{route="other",status="404"} 1`,
+		`http_request_duration_seconds_count{route="/create_event",status="200"} 1`,
+		`http_request_duration_seconds_bucket{route="/create_event",status="200",le="+Inf"} 1`,
+		`http_errors_total{class="validation"} 1`,
+		`http_errors_total{class="business"} 1`,
+		`calendar_business_errors_total{error="event not found"} 1`,
+		`calendar_events 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Metrics do not contain %q:\n%s", line, body)
+		}
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := &histogram{counts: make([]uint64, len(durationBuckets))}
+	h.observe(0.003)
+	h.observe(0.2)
+	h.observe(20)
+
+	// корзины кумулятивные: в каждую входят все наблюдения меньших корзин
+	want := map[float64]uint64{0.005: 1, 0.1: 1, 0.25: 2, 10: 2}
+	for i, bound := range durationBuckets {
+		if n, ok := want[bound]; ok && h.counts[i] != n {
+			t.Errorf("Bucket le=%v: got %d, want %d", bound, h.counts[i], n)
+		}
+	}
+	if h.count != 3 || h.sum != 20.203 {
+		t.Errorf("Unexpected count %d and sum %v", h.count, h.sum)
+	}
+}
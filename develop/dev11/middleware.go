@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Классы ошибок в логах, по одному на каждый код ответа writeError
+const (
+	errorClassValidation = "validation"
+	errorClassBusiness   = "business"
+	errorClassInternal   = "internal"
+	errorClassAuth       = "auth"
+	errorClassLimit      = "limit"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userIDKey
+)
+
+// requestIDFromContext возвращает id запроса, выставленный loggingMiddleware
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// statusRecorder запоминает код ответа, число записанных байт,
+// класс ошибки, о которой сообщил writeError, и пользователя,
+// которого аутентифицировал authMiddleware
+type statusRecorder struct {
+	http.ResponseWriter
+	status     int
+	bytes      int
+	errorClass string
+	err        error
+	userID     int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap дает http.ResponseController доступ к Flush и дедлайнам исходного writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) recordError(class string, err error) {
+	r.errorClass = class
+	r.err = err
+}
+
+func (r *statusRecorder) recordUser(userID int) {
+	r.userID = userID
+}
+
+// errorRecorder реализуют writer'ы, которым writeError сообщает класс ошибки
+type errorRecorder interface {
+	recordError(class string, err error)
+}
+
+// userRecorder реализуют writer'ы, которым authMiddleware сообщает пользователя
+type userRecorder interface {
+	recordUser(userID int)
+}
+
+// newRecorder оборачивает w, переиспользуя уже существующий statusRecorder
+func newRecorder(w http.ResponseWriter) *statusRecorder {
+	if rec, ok := w.(*statusRecorder); ok {
+		return rec
+	}
+	return &statusRecorder{ResponseWriter: w}
+}
+
+// Middleware для логов: пишет структурированную запись о каждом запросе
+// и проставляет X-Request-ID, принимая его от клиента или генерируя новый
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, requestID))
+
+		rec := newRecorder(w)
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		// форму разбирает обработчик, сам middleware тело не читает
+		if rec.userID != 0 {
+			attrs = append(attrs, slog.String("user_id", strconv.Itoa(rec.userID)))
+		} else if r.Form != nil && r.Form.Get("user_id") != "" {
+			attrs = append(attrs, slog.String("user_id", r.Form.Get("user_id")))
+		}
+		if rec.errorClass != "" {
+			attrs = append(attrs, slog.String("error_class", rec.errorClass), slog.String("error", rec.err.Error()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case rec.status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// validRequestID принимает id клиента, только если он короткий и печатный,
+// чтобы в логи нельзя было подсунуть произвольный текст
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
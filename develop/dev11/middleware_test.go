@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// captureLogs перенаправляет slog в буфер в JSON формате на время теста
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func decodeLogEntry(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Log is not JSON: %v: %s", err, buf.String())
+	}
+	return entry
+}
+
+func TestLoggingMiddlewareLogsRequest(t *testing.T) {
+	resetEvents()
+	logs := captureLogs(t)
+
+	form := url.Values{"user_id": {"7"}, "date": {"2024-05-30"}}
+	req := httptest.NewRequest("POST", "/create_event", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(requestIDHeader, "abc-123")
+	rr := httptest.NewRecorder()
+	loggingMiddleware(http.HandlerFunc(createEventHandler)).ServeHTTP(rr, req)
+
+	if got := rr.Header().Get(requestIDHeader); got != "abc-123" {
+		t.Errorf("X-Request-ID = %q, want propagated id", got)
+	}
+
+	entry := decodeLogEntry(t, logs)
+	want := map[string]interface{}{
+		"level":       "WARN",
+		"msg":         "request",
+		"request_id":  "abc-123",
+		"method":      "POST",
+		"path":        "/create_event",
+		"status":      float64(http.StatusBadRequest),
+		"bytes":       float64(rr.Body.Len()),
+		"user_id":     "7",
+		"error_class": errorClassValidation,
+		"error":       "title is required",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("log[%q] = %v, want %v", key, entry[key], value)
+		}
+	}
+}
+
+func TestLoggingMiddlewareGeneratesRequestID(t *testing.T) {
+	captureLogs(t)
+
+	for _, incoming := range []string{"", "bad id with spaces", strings.Repeat("x", 200)} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if incoming != "" {
+			req.Header.Set(requestIDHeader, incoming)
+		}
+		rr := httptest.NewRecorder()
+		var seen string
+		loggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = requestIDFromContext(r.Context())
+		})).ServeHTTP(rr, req)
+
+		got := rr.Header().Get(requestIDHeader)
+		if len(got) != 32 || got == incoming {
+			t.Errorf("incoming %q: expected generated id, got %q", incoming, got)
+		}
+		if seen != got {
+			t.Errorf("context id %q differs from header %q", seen, got)
+		}
+	}
+}
+
+func TestStatusRecorderFlush(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rec := newRecorder(rr)
+	if err := http.NewResponseController(rec).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !rr.Flushed {
+		t.Error("Flush was not passed to the underlying writer")
+	}
+	if newRecorder(rec) != rec {
+		t.Error("newRecorder must reuse an existing recorder")
+	}
+}
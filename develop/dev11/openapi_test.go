@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"httptask/calendar"
+	"httptask/client"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	withAuth(t, hmacAuthenticator{secret: testSecret})
+	rr := serveRouter(httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 without token, got %d", rr.Code)
+	}
+
+	var spec struct {
+		OpenAPI    string                 `json:"openapi"`
+		Paths      map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas struct {
+				Category struct {
+					Enum []string `json:"enum"`
+				} `json:"Category"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI[:2] != "3." {
+		t.Errorf("Unexpected OpenAPI version %q", spec.OpenAPI)
+	}
+	for _, path := range []string{"/create_event", "/update_event", "/delete_event", "/events_for_day", "/events_for_week", "/events_for_month", "/invite_attendees", "/respond_to_invite"} {
+		if spec.Paths[path] == nil {
+			t.Errorf("Path %s is not described", path)
+		}
+	}
+	if categories := spec.Components.Schemas.Category.Enum; !slices.Equal(categories, calendar.Categories) {
+		t.Errorf("Categories in spec %v differ from %v", categories, calendar.Categories)
+	}
+}
+
+// TestClient проверяет, что пакет client согласован с сервером
+func TestClient(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	server := httptest.NewServer(newRouter())
+	defer server.Close()
+
+	ctx := context.Background()
+	c := client.New(server.URL)
+	monday := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	// событие другого пользователя, чтобы id не совпадали с номерами по порядку
+	c.CreateEvent(ctx, 5, client.EventParams{Start: monday, Title: "Other"})
+	id, version, err := c.CreateEvent(ctx, 1, client.EventParams{Start: monday.Add(10 * time.Hour), Duration: time.Hour, Title: "Standup"})
+	if err != nil || id != 2 || version != 1 {
+		t.Fatalf("CreateEvent = %d, %d, %v; want id 2, version 1", id, version, err)
+	}
+	version, err = c.UpdateEvent(ctx, 1, id, client.EventParams{Start: monday.AddDate(0, 0, 1), Title: "Retro", Version: 1})
+	if err != nil || version != 2 {
+		t.Fatalf("UpdateEvent = %d, %v; want version 2", version, err)
+	}
+
+	events, err := c.EventsForWeek(ctx, 1, monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Title != "Retro" || !events[0].Date.Equal(monday.AddDate(0, 0, 1)) {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+
+	err = c.DeleteEvent(ctx, 1, id, 1)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected version mismatch, got %v", err)
+	}
+	if err := c.DeleteEvent(ctx, 1, id, version); err != nil {
+		t.Fatal(err)
+	}
+	planning, version, err := c.CreateEvent(ctx, 1, client.EventParams{Start: monday, Title: "Planning"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.InviteAttendees(ctx, 1, planning, []int{3}, version); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RespondToInvite(ctx, 3, planning, calendar.RSVPAccepted); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := c.EventsForDay(ctx, 3, monday); err != nil || len(events) != 1 || events[0].Attendees[0].Status != calendar.RSVPAccepted {
+		t.Errorf("EventsForDay = %+v, %v; want accepted invitation", events, err)
+	}
+
+	if events, err := c.EventsForMonth(ctx, 2, monday); err != nil || len(events) != 0 {
+		t.Errorf("EventsForMonth = %+v, %v; want none", events, err)
+	}
+}
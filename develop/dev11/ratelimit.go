@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ограничитель частоты запросов, nil — без ограничения; задается в run
+var limiter *rateLimiter
+
+var errRateLimited = &statusError{status: http.StatusTooManyRequests, class: errorClassLimit, msg: "rate limit exceeded"}
+
+// rateLimiter — token bucket на каждого клиента: корзина вмещает burst
+// запросов и пополняется со скоростью rate запросов в секунду
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow забирает токен из корзины клиента key. Если корзина пуста,
+// возвращает время, через которое появится следующий токен.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep раз в минуту удаляет корзины, которые успели заполниться:
+// они ничем не отличаются от новых, а без очистки карта растет
+// с каждым новым клиентом
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitMiddleware ограничивает частоту запросов клиента: пользователя,
+// если запрос аутентифицирован, иначе IP адреса. Превышение — 429
+// с Retry-After в секундах.
+func rateLimitMiddleware(l *rateLimiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.reject(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reject забирает токен клиента запроса r и, если корзина пуста,
+// отвечает 429. Ограничитель nil ничего не ограничивает.
+func (l *rateLimiter) reject(w http.ResponseWriter, r *http.Request) bool {
+	if l == nil {
+		return false
+	}
+	ok, wait := l.allow(clientKey(r))
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeError(w, errRateLimited)
+	}
+	return !ok
+}
+
+func clientKey(r *http.Request) string {
+	if userID, ok := r.Context().Value(userIDKey).(int); ok {
+		return "user:" + strconv.Itoa(userID)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRefills(t *testing.T) {
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(2, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a"); !ok {
+			t.Fatalf("Request %d within burst was limited", i+1)
+		}
+	}
+	ok, wait := l.allow("a")
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("allow = %v, %v; want false, 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("b"); !ok {
+		t.Error("Expected separate bucket for another client")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.allow("a"); !ok {
+		t.Error("Expected token after refill")
+	}
+
+	// заполненные корзины удаляются при очистке
+	now = now.Add(2 * time.Minute)
+	l.allow("c")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("Expected idle bucket to be swept")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	previous := limiter
+	limiter = newRateLimiter(1, 1)
+	t.Cleanup(func() { limiter = previous })
+
+	req := httptest.NewRequest("GET", "/events_for_day?user_id=1&date=2024-06-03", nil)
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	rr := serveRouter(req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+
+	// аутентифицированные пользователи ограничиваются независимо от адреса
+	withAuth(t, hmacAuthenticator{secret: testSecret})
+	for _, userID := range []int{1, 2} {
+		req := httptest.NewRequest("GET", "/events_for_day?date=2024-06-03", nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(testSecret, userID, time.Now().Add(time.Hour)))
+		if rr := serveRouter(req); rr.Code != http.StatusOK {
+			t.Errorf("User %d: expected 200, got %d", userID, rr.Code)
+		}
+	}
+}
+
+func TestRateLimitFailedAuth(t *testing.T) {
+	captureLogs(t)
+	withAuth(t, hmacAuthenticator{secret: testSecret})
+	previous := limiter
+	limiter = newRateLimiter(1, 2)
+	t.Cleanup(func() { limiter = previous })
+
+	// перебор токенов ограничивается по адресу клиента
+	var codes []int
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/events_for_day?date=2024-06-03", nil)
+		req.Header.Set("Authorization", "Bearer bad-token")
+		codes = append(codes, serveRouter(req).Code)
+	}
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	if !slices.Equal(codes, want) {
+		t.Errorf("Expected %v, got %v", want, codes)
+	}
+}
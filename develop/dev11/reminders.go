@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"httptask/calendar"
+)
+
+// notifier доставляет сработавшие напоминания
+type notifier interface {
+	Notify(ctx context.Context, reminder calendar.Reminder) error
+}
+
+func newNotifier(config Config) notifier {
+	switch config.Notifier {
+	case "webhook":
+		return &webhookNotifier{url: config.WebhookURL, client: &http.Client{Timeout: 5 * time.Second}}
+	case "sse":
+		return reminderStream
+	default:
+		return logNotifier{}
+	}
+}
+
+// runReminders раз в interval отправляет напоминания, сработавшие с прошлой
+// проверки, и завершается при отмене ctx. Напоминания хранятся вместе
+// с событиями и переживают перезапуск, но сработавшие, пока сервер
+// был остановлен, не отправляются.
+func runReminders(ctx context.Context, n notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sendReminders(ctx, n, last, now)
+			last = now
+		}
+	}
+}
+
+// sendReminders отправляет напоминания со временем срабатывания в [from, to)
+func sendReminders(ctx context.Context, n notifier, from, to time.Time) {
+	reminders, err := cal.DueReminders(from, to)
+	if err != nil {
+		slog.Error("Listing reminders", "error", err)
+		return
+	}
+	for _, reminder := range reminders {
+		if err := n.Notify(ctx, reminder); err != nil {
+			slog.Warn("Sending reminder", "event_id", reminder.EventID, "user_id", reminder.UserID, "error", err)
+		}
+	}
+}
+
+// logNotifier пишет напоминания в лог
+type logNotifier struct{}
+
+func (logNotifier) Notify(ctx context.Context, reminder calendar.Reminder) error {
+	slog.InfoContext(ctx, "reminder",
+		"event_id", reminder.EventID,
+		"user_id", reminder.UserID,
+		"title", reminder.Title,
+		"start", reminder.Start,
+		"minutes", reminder.Minutes,
+	)
+	return nil
+}
+
+// webhookNotifier отправляет напоминание POST запросом с JSON телом
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, reminder calendar.Reminder) error {
+	body, err := json.Marshal(reminder)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentTypeJSON)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Подписчики /reminders_stream, заменяется в run
+var reminderStream = newSSENotifier()
+
+// sseNotifier рассылает напоминания подписчикам потока пользователя.
+// Медленный подписчик с заполненным буфером пропускает напоминания.
+type sseNotifier struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan calendar.Reminder]struct{}
+	closed      bool
+}
+
+func newSSENotifier() *sseNotifier {
+	return &sseNotifier{subscribers: make(map[int]map[chan calendar.Reminder]struct{})}
+}
+
+func (n *sseNotifier) Notify(ctx context.Context, reminder calendar.Reminder) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subscribers[reminder.UserID] {
+		select {
+		case ch <- reminder:
+		default:
+		}
+	}
+	return nil
+}
+
+// subscribe возвращает канал напоминаний пользователя. Канал закрывается
+// при остановке сервера, после отписки из него больше ничего не придет.
+func (n *sseNotifier) subscribe(userID int) (<-chan calendar.Reminder, func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ch := make(chan calendar.Reminder, 16)
+	if n.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if n.subscribers[userID] == nil {
+		n.subscribers[userID] = make(map[chan calendar.Reminder]struct{})
+	}
+	n.subscribers[userID][ch] = struct{}{}
+
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if _, ok := n.subscribers[userID][ch]; ok {
+			delete(n.subscribers[userID], ch)
+			close(ch)
+		}
+	}
+}
+
+// close завершает все потоки, чтобы они не задерживали остановку сервера
+func (n *sseNotifier) close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	for userID, subscribers := range n.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+		delete(n.subscribers, userID)
+	}
+}
+
+// reminderStreamHandler отдает напоминания пользователя потоком Server-Sent Events
+func reminderStreamHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	reminders, unsubscribe := reminderStream.subscribe(userID)
+	defer unsubscribe()
+
+	rc := startSSE(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case reminder, ok := <-reminders:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, rc, "", "reminder", reminder); err != nil {
+				return
+			}
+		}
+	}
+}
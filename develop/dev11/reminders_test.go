@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"httptask/calendar"
+)
+
+func TestSendRemindersWebhook(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+
+	received := make(chan calendar.Reminder, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reminder calendar.Reminder
+		if err := json.NewDecoder(r.Body).Decode(&reminder); err != nil {
+			t.Errorf("Invalid webhook body: %v", err)
+		}
+		received <- reminder
+	}))
+	defer server.Close()
+
+	form := url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "start_time": {"10:00"}, "title": {"Meeting"}, "reminders": {"15"}}
+	if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	n := newNotifier(Config{Notifier: "webhook", WebhookURL: server.URL})
+	start := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	sendReminders(context.Background(), n, start.Add(-time.Hour), start)
+
+	select {
+	case reminder := <-received:
+		if reminder.Title != "Meeting" || reminder.Minutes != 15 || !reminder.FireAt.Equal(start.Add(-15*time.Minute)) {
+			t.Errorf("Unexpected reminder: %+v", reminder)
+		}
+	default:
+		t.Fatal("Webhook was not called")
+	}
+}
+
+func TestRemindersRejectInvalid(t *testing.T) {
+	resetEvents()
+	form := url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Meeting"}, "reminders": {"15,soon"}}
+	if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReminderStream(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	reminderStream = newSSENotifier()
+
+	server := httptest.NewServer(newRouter())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/reminders_stream?user_id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected Content-Type %q", ct)
+	}
+
+	// заголовки отправлены после подписки, так что напоминание не потеряется
+	reminderStream.Notify(context.Background(), calendar.Reminder{EventID: 2, UserID: 2, Title: "Other user"})
+	reminderStream.Notify(context.Background(), calendar.Reminder{EventID: 1, UserID: 1, Title: "Meeting"})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	if lines[0] != "event: reminder" || !strings.Contains(lines[1], `"title":"Meeting"`) {
+		t.Errorf("Unexpected stream: %q", lines)
+	}
+
+	// остановка сервера закрывает поток
+	reminderStream.close()
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Error("Expected stream to end")
+	}
+}
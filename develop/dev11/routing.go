@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"httptask/calendar"
+)
+
+// Типы тела POST запросов с параметрами
+const (
+	contentTypeForm      = "application/x-www-form-urlencoded"
+	contentTypeMultipart = "multipart/form-data"
+	contentTypeJSON      = "application/json"
+)
+
+// allowMethods пропускает к h только запросы с перечисленными методами,
+// на остальные отвечает 405 с заголовком Allow
+func allowMethods(h http.Handler, methods ...string) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	})
+}
+
+// get и post — маршруты для GET (вместе с HEAD) и POST методов API
+func get(h http.HandlerFunc) http.Handler {
+	return allowMethods(h, http.MethodGet, http.MethodHead)
+}
+
+func post(h http.HandlerFunc) http.Handler {
+	return allowMethods(formBody(h), http.MethodPost)
+}
+
+// requireContentType отвечает 415 на запросы с телом другого типа.
+// Запрос без тела пропускается: параметры могут быть в query.
+func requireContentType(h http.Handler, types ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength == 0 && r.Header.Get("Content-Type") == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err == nil {
+			for _, t := range types {
+				if mediaType == t {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{
+			"error": "unsupported content type, expected " + strings.Join(types, " or "),
+		})
+	})
+}
+
+// Максимальный размер тела с параметрами
+const maxFormSize = 1 << 20
+
+var errBodyTooLarge = &statusError{status: http.StatusRequestEntityTooLarge, class: errorClassLimit, msg: "request body too large"}
+
+// formBody принимает параметры в теле как www-url-form-encoded, multipart
+// или JSON объект. JSON переводится в r.Form, так что обработчики читают
+// параметры через FormValue независимо от формата.
+func formBody(h http.Handler) http.Handler {
+	return requireContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if err := parseBody(r); err != nil {
+			writeError(w, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}), contentTypeForm, contentTypeMultipart, contentTypeJSON)
+}
+
+// parseBody разбирает тело заранее: FormValue молча игнорирует ошибки
+// чтения, и слишком большое тело выглядело бы как пустая форма
+func parseBody(r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var err error
+	switch mediaType {
+	case contentTypeJSON:
+		err = parseJSONForm(r)
+	case contentTypeMultipart:
+		err = r.ParseMultipartForm(maxFormSize)
+	default:
+		err = r.ParseForm()
+	}
+
+	var tooLarge *http.MaxBytesError
+	var validationErr *calendar.ValidationError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &tooLarge):
+		return errBodyTooLarge
+	case errors.As(err, &validationErr):
+		return err
+	default:
+		return &calendar.ValidationError{Msg: "invalid form body"}
+	}
+}
+
+// parseJSONForm заполняет r.Form и r.PostForm из JSON объекта в теле.
+// Числа и булевы значения переводятся в строки, массивы — в строку через запятую.
+func parseJSONForm(r *http.Request) error {
+	var body map[string]interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return &calendar.ValidationError{Msg: "invalid JSON body"}
+	}
+
+	postForm, err := jsonForm(body)
+	if err != nil {
+		return err
+	}
+
+	form := make(url.Values)
+	for key, values := range postForm {
+		form[key] = values
+	}
+	for key, values := range r.URL.Query() {
+		form[key] = append(form[key], values...)
+	}
+	r.PostForm = postForm
+	r.Form = form
+	return nil
+}
+
+// jsonForm переводит поля JSON объекта в параметры формы
+func jsonForm(body map[string]interface{}) (url.Values, error) {
+	values := make(url.Values, len(body))
+	for key, value := range body {
+		s, err := formString(value)
+		if err != nil {
+			return nil, &calendar.ValidationError{Msg: "invalid " + key}
+		}
+		values.Set(key, s)
+	}
+	return values, nil
+}
+
+func formString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case nil:
+		return "", nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := formString(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
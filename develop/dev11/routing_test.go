@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveRouter(req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRouterRejectsWrongMethod(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{"GET", "/delete_event?id=1&user_id=1", "POST"},
+		{"PUT", "/create_event", "POST"},
+		{"POST", "/events_for_day", "GET, HEAD"},
+		{"DELETE", "/free_busy", "GET, HEAD"},
+	}
+	for _, tt := range tests {
+		rr := serveRouter(httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, rr.Code, http.StatusMethodNotAllowed)
+		}
+		if got := rr.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.allow)
+		}
+		if strings.TrimSpace(rr.Body.String()) != `{"error":"method not allowed"}` {
+			t.Errorf("%s %s: unexpected body %s", tt.method, tt.path, rr.Body.String())
+		}
+	}
+}
+
+func TestRouterRejectsUnsupportedContentType(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+
+	req := httptest.NewRequest("POST", "/create_event", strings.NewReader("user_id=1"))
+	req.Header.Set("Content-Type", "text/plain")
+	rr := serveRouter(req)
+
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("got %d, want %d", rr.Code, http.StatusUnsupportedMediaType)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("handler returned wrong content type: got %v", ct)
+	}
+}
+
+func TestRouterAcceptsJSONBody(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+
+	req := httptest.NewRequest("POST", "/create_event", strings.NewReader(`{"user_id": 1, "date": "2024-05-30", "title": "JSON event"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rr := serveRouter(req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serveRouter(httptest.NewRequest("GET", "/events_for_day?user_id=1&date=2024-05-30", nil))
+	expected := `[{"id":1,"user_id":1,"date":"2024-05-30T00:00:00Z","title":"JSON event","version":1}]`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	req = httptest.NewRequest("POST", "/create_event", strings.NewReader(`{"user_id": 1,`))
+	req.Header.Set("Content-Type", "application/json")
+	if rr := serveRouter(req); rr.Code != http.StatusBadRequest {
+		t.Errorf("malformed JSON: got %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+
+	req = httptest.NewRequest("POST", "/create_event", strings.NewReader(`{"user_id": {"id": 1}}`))
+	req.Header.Set("Content-Type", "application/json")
+	if rr := serveRouter(req); rr.Code != http.StatusBadRequest {
+		t.Errorf("nested object: got %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRouterRejectsLargeBody(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+
+	body := `{"user_id": 1, "date": "2024-05-30", "title": "` + strings.Repeat("a", maxFormSize) + `"}`
+	req := httptest.NewRequest("POST", "/create_event", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if rr := serveRouter(req); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d, want %d", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	req = httptest.NewRequest("POST", "/create_event", strings.NewReader("title="+strings.Repeat("a", maxFormSize)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if rr := serveRouter(req); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("form: got %d, want %d", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+}
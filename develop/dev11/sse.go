@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"httptask/calendar"
+)
+
+// startSSE отправляет заголовки потока Server-Sent Events
+func startSSE(w http.ResponseWriter) *http.ResponseController {
+	// поток живет дольше WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+	return rc
+}
+
+// writeSSE отправляет одно событие потока; пустой id не передается
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, id, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// eventsStreamHandler отдает изменения событий пользователя потоком
+// Server-Sent Events с id вида <epoch>-<seq>. Клиент, переподключившийся
+// с Last-Event-ID
+// (или параметром last_event_id), получает пропущенные изменения,
+// а если их уже нет — событие reset, после которого события нужно
+// перечитать.
+func eventsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.FormValue("last_event_id")
+	}
+	// id без epoch остался от прошлых версий и ведет к сбросу
+	var epoch string
+	var lastSeq int64
+	if lastID != "" {
+		seq := lastID
+		if i := strings.LastIndex(lastID, "-"); i >= 0 {
+			epoch, seq = lastID[:i], lastID[i+1:]
+		}
+		lastSeq, err = strconv.ParseInt(seq, 10, 64)
+		if err != nil || lastSeq < 0 {
+			writeError(w, &calendar.ValidationError{Msg: "invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	hub := cal.Changes()
+	backlog, changes, unsubscribe := hub.Subscribe(userID, epoch, lastSeq)
+	defer unsubscribe()
+
+	rc := startSSE(w)
+	send := func(change calendar.Change) error {
+		id := hub.Epoch() + "-" + strconv.FormatInt(change.Seq, 10)
+		if change.Type == calendar.ChangeReset {
+			// клиенту нужно перечитать события, самого события в сбросе нет
+			return writeSSE(w, rc, id, string(change.Type), map[string]int64{"seq": change.Seq})
+		}
+		return writeSSE(w, rc, id, string(change.Type), change)
+	}
+	for _, change := range backlog {
+		if err := send(change); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			if err := send(change); err != nil {
+				return
+			}
+		}
+	}
+}
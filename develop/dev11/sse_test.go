@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"httptask/calendar"
+)
+
+// readSSE читает одно событие потока: поля до пустой строки
+func readSSE(t *testing.T, reader *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return fields
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		fields[name] = value
+	}
+}
+
+func openStream(t *testing.T, server *httptest.Server, path, lastEventID string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("GET", server.URL+path, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestEventsStream(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	server := httptest.NewServer(newRouter())
+	// Close ждет завершения запросов, поэтому выполняется после закрытия потоков
+	t.Cleanup(server.Close)
+
+	resp := openStream(t, server, "/events/stream?user_id=1", "")
+	reader := bufio.NewReader(resp.Body)
+
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"2"}, "date": {"2024-06-03"}, "title": {"Other user"}})
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Standup"}})
+	postForm(deleteEventHandler, "/delete_event", url.Values{"user_id": {"1"}, "id": {"2"}})
+
+	epoch := cal.Changes().Epoch()
+	created := readSSE(t, reader)
+	if created["id"] != epoch+"-2" || created["event"] != "created" || !strings.Contains(created["data"], `"title":"Standup"`) {
+		t.Errorf("Unexpected created event: %v", created)
+	}
+	if deleted := readSSE(t, reader); deleted["id"] != epoch+"-3" || deleted["event"] != "deleted" {
+		t.Errorf("Unexpected deleted event: %v", deleted)
+	}
+
+	// переподключение с Last-Event-ID отдает пропущенные изменения
+	resumed := openStream(t, server, "/events/stream?user_id=1", epoch+"-2")
+	if event := readSSE(t, bufio.NewReader(resumed.Body)); event["id"] != epoch+"-3" || event["event"] != "deleted" {
+		t.Errorf("Unexpected resumed event: %v", event)
+	}
+}
+
+func TestEventsStreamReset(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	server := httptest.NewServer(newRouter())
+	t.Cleanup(server.Close)
+
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Standup"}})
+
+	// Last-Event-ID из прошлого запуска сервера с тем же номером
+	// и id без epoch: пропущенное не восстановить
+	epoch := cal.Changes().Epoch()
+	for _, lastID := range []string{calendar.NewHub().Epoch() + "-1", "1"} {
+		resp := openStream(t, server, "/events/stream?user_id=1", lastID)
+		event := readSSE(t, bufio.NewReader(resp.Body))
+		if event["id"] != epoch+"-1" || event["event"] != "reset" || event["data"] != `{"seq":1}` {
+			t.Errorf("%s: unexpected reset event: %v", lastID, event)
+		}
+	}
+
+	resp := openStream(t, server, "/events/stream?user_id=1", epoch+"-1")
+	reader := bufio.NewReader(resp.Body)
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-06-04"}, "title": {"Retro"}})
+	if event := readSSE(t, reader); event["id"] != epoch+"-2" || event["event"] != "created" {
+		t.Errorf("Expected changes after reset, got %v", event)
+	}
+}
+
+func TestEventsStreamInvalidLastEventID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/events/stream?user_id=1", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	if rr := serveRouter(req); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rr.Code)
+	}
+}
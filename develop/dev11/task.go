@@ -23,33 +23,25 @@ package main
 */
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
-)
 
-// событие
-type Event struct {
-	ID     int       `json:"id"`
-	UserID int       `json:"user_id"`
-	Date   time.Time `json:"date"`
-	Title  string    `json:"title"`
-}
+	"httptask/calendar"
+)
 
-// In-memory хранилище для events
-var events = make(map[int]Event)
-var nextID = 1
-
-// Middleware для логов
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
-	})
-}
+// Сервис календаря, хранилище выбирается в run по конфигу
+var cal = calendar.NewService(calendar.NewMemoryStore())
 
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -59,170 +51,696 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	}
 }
 
+// statusError — ошибка HTTP уровня со своим кодом ответа и классом для логов
+type statusError struct {
+	status int
+	class  string
+	msg    string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// writeError отдает ошибку в виде {"error": "..."} с кодом по ее типу:
+// 400 для ошибок входных данных, 503 для ошибок бизнес-логики, код
+// statusError для ошибок доступа, 500 для остальных.
+// Класс ошибки передается в loggingMiddleware и попадает в лог запроса.
+func writeError(w http.ResponseWriter, err error) {
+	var validationErr *calendar.ValidationError
+	var businessErr *calendar.BusinessError
+	var statusErr *statusError
+	status, class, msg := http.StatusInternalServerError, errorClassInternal, "internal error"
+	switch {
+	case errors.As(err, &statusErr):
+		status, class, msg = statusErr.status, statusErr.class, statusErr.msg
+	case errors.As(err, &validationErr):
+		status, class, msg = http.StatusBadRequest, errorClassValidation, err.Error()
+	case errors.As(err, &businessErr):
+		status, class, msg = http.StatusServiceUnavailable, errorClassBusiness, err.Error()
+	}
+
+	if rec, ok := w.(errorRecorder); ok {
+		rec.recordError(class, err)
+	} else if class == errorClassInternal {
+		log.Printf("Internal error: %v", err)
+	}
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
 func parseIntParam(r *http.Request, key string) (int, error) {
-	return strconv.Atoi(r.FormValue(key))
+	value, err := strconv.Atoi(r.FormValue(key))
+	if err != nil {
+		return 0, &calendar.ValidationError{Msg: "invalid " + key}
+	}
+	return value, nil
 }
 
-func parseDateParam(r *http.Request, key string) (time.Time, error) {
-	return time.Parse("2006-01-02", r.FormValue(key))
+func parseBoolParam(r *http.Request, key string) (bool, error) {
+	value, err := strconv.ParseBool(r.FormValue(key))
+	if err != nil {
+		return false, &calendar.ValidationError{Msg: "invalid " + key}
+	}
+	return value, nil
+}
+
+// parseIntListParam разбирает список чисел через запятую
+func parseIntListParam(r *http.Request, key string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(r.FormValue(key), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, &calendar.ValidationError{Msg: "invalid " + key}
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// parseListParam разбирает список строк через запятую, пустой параметр — nil
+func parseListParam(r *http.Request, key string) []string {
+	if r.FormValue(key) == "" {
+		return nil
+	}
+	values := strings.Split(r.FormValue(key), ",")
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// parseLocation возвращает зону из параметра tz, по умолчанию UTC
+func parseLocation(r *http.Request) (*time.Location, error) {
+	name := r.FormValue("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, &calendar.ValidationError{Msg: "invalid tz"}
+	}
+	return loc, nil
+}
+
+// parseDateParam разбирает дату вида 2006-01-02 как полночь в зоне loc
+func parseDateParam(r *http.Request, key string, loc *time.Location) (time.Time, error) {
+	date, err := time.ParseInLocation("2006-01-02", r.FormValue(key), loc)
+	if err != nil {
+		return time.Time{}, &calendar.ValidationError{Msg: "invalid " + key}
+	}
+	return date, nil
+}
+
+// parseTimeParam разбирает момент времени в RFC 3339 или дату вида 2006-01-02
+func parseTimeParam(r *http.Request, key string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, r.FormValue(key)); err == nil {
+		return t, nil
+	}
+	return parseDateParam(r, key, loc)
+}
+
+// parseVersion возвращает ожидаемую версию события из заголовка If-Match
+// или поля version; 0 — версия не передана или If-Match: *
+func parseVersion(r *http.Request) (int, error) {
+	value := r.FormValue("version")
+	if match := r.Header.Get("If-Match"); match != "" {
+		if match == "*" {
+			return 0, nil
+		}
+		value = strings.Trim(strings.TrimPrefix(match, "W/"), `"`)
+	}
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, &calendar.ValidationError{Msg: "invalid version"}
+	}
+	return version, nil
+}
+
+// setETag выставляет ETag с версией события для последующего If-Match
+func setETag(w http.ResponseWriter, event calendar.Event) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(event.Version)))
+}
+
+// Заголовок с id созданного события: тело ответа остается {"result": "..."}
+const eventIDHeader = "X-Event-ID"
+
+// parseRangeParams разбирает необязательный период from и to.
+// Без обоих параметров возвращаются нулевые моменты.
+func parseRangeParams(r *http.Request, loc *time.Location) (time.Time, time.Time, error) {
+	if r.FormValue("from") == "" && r.FormValue("to") == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	from, err := parseDateParam(r, "from", loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseDateParam(r, "to", loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return from, to, nil
+}
+
+// parseWeekdayParam разбирает день недели: monday или mo, без учета регистра
+func parseWeekdayParam(r *http.Request, key string) (time.Weekday, error) {
+	value := strings.ToLower(r.FormValue(key))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:2] {
+			return day, nil
+		}
+	}
+	return 0, &calendar.ValidationError{Msg: "invalid " + key}
+}
+
+// parseClockParam разбирает время суток вида 15:04 и ставит его на дату date
+func parseClockParam(r *http.Request, key string, date time.Time) (time.Time, error) {
+	clock, err := time.Parse("15:04", r.FormValue(key))
+	if err != nil {
+		return time.Time{}, &calendar.ValidationError{Msg: "invalid " + key}
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, date.Location()), nil
+}
+
+// parseEventParams разбирает общие параметры /create_event и /update_event:
+// date, start_time и end_time (или duration) в зоне tz, all_day, title,
+// description, location, color, categories через запятую, rrule
+// и reminders — минуты до начала через запятую. Значения полей проверяет
+// календарь, одинаково для всех способов изменить событие.
+func parseEventParams(r *http.Request) (calendar.EventParams, error) {
+	loc, err := parseLocation(r)
+	if err != nil {
+		return calendar.EventParams{}, err
+	}
+
+	date, err := parseDateParam(r, "date", loc)
+	if err != nil {
+		return calendar.EventParams{}, err
+	}
+
+	params := calendar.EventParams{
+		Date:        date,
+		Title:       r.FormValue("title"),
+		Description: r.FormValue("description"),
+		Location:    r.FormValue("location"),
+		Color:       r.FormValue("color"),
+		Categories:  parseListParam(r, "categories"),
+	}
+	if r.FormValue("tz") != "" {
+		params.TimeZone = loc.String()
+	}
+
+	if r.FormValue("start_time") != "" {
+		params.Date, err = parseClockParam(r, "start_time", date)
+		if err != nil {
+			return calendar.EventParams{}, err
+		}
+	}
+
+	switch {
+	case r.FormValue("end_time") != "" && r.FormValue("duration") != "":
+		return calendar.EventParams{}, &calendar.ValidationError{Msg: "end_time and duration are mutually exclusive"}
+	case r.FormValue("end_time") != "":
+		end, err := parseClockParam(r, "end_time", date)
+		if err != nil {
+			return calendar.EventParams{}, err
+		}
+		params.End = &end
+	case r.FormValue("duration") != "":
+		duration, err := time.ParseDuration(r.FormValue("duration"))
+		if err != nil || duration <= 0 {
+			return calendar.EventParams{}, &calendar.ValidationError{Msg: "invalid duration"}
+		}
+		end := params.Date.Add(duration)
+		params.End = &end
+	}
+
+	if rrule := r.FormValue("rrule"); rrule != "" {
+		params.RRule, err = calendar.ParseRRule(rrule)
+		if err != nil {
+			return calendar.EventParams{}, err
+		}
+	}
+
+	if r.FormValue("reminders") != "" {
+		params.Reminders, err = parseIntListParam(r, "reminders")
+		if err != nil {
+			return calendar.EventParams{}, err
+		}
+	}
+
+	if r.FormValue("all_day") != "" {
+		params.AllDay, err = parseBoolParam(r, "all_day")
+		if err != nil {
+			return calendar.EventParams{}, err
+		}
+	}
+
+	if r.FormValue("reject_conflicts") != "" {
+		params.RejectConflicts, err = parseBoolParam(r, "reject_conflicts")
+		if err != nil {
+			return calendar.EventParams{}, err
+		}
+	}
+	return params, nil
 }
 
 // Обработчики
 func createEventHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := parseIntParam(r, "user_id")
+	userID, err := requestUserID(r)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	date, err := parseDateParam(r, "date")
+	params, err := parseEventParams(r)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid date"}`, http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	title := r.FormValue("title")
-	if title == "" {
-		http.Error(w, `{"error": "Title is required"}`, http.StatusBadRequest)
+	event, err := cal.CreateEvent(userID, params)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	event := Event{
-		ID:     nextID,
-		UserID: userID,
-		Date:   date,
-		Title:  title,
-	}
-	events[nextID] = event
-	nextID++
-
+	setETag(w, event)
+	w.Header().Set(eventIDHeader, strconv.Itoa(event.ID))
 	writeJSON(w, http.StatusOK, map[string]string{"result": "Event created"})
 }
 
 func updateEventHandler(w http.ResponseWriter, r *http.Request) {
 	eventID, err := parseIntParam(r, "id")
 	if err != nil {
-		http.Error(w, `{"error": "Invalid event id"}`, http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	event, exists := events[eventID]
-	if !exists {
-		http.Error(w, `{"error": "Event not found"}`, http.StatusServiceUnavailable)
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	userID, err := parseIntParam(r, "user_id")
+	params, err := parseEventParams(r)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	date, err := parseDateParam(r, "date")
-	if err != nil {
-		http.Error(w, `{"error": "Invalid date"}`, http.StatusBadRequest)
+	if params.Version, err = parseVersion(r); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	title := r.FormValue("title")
-	if title == "" {
-		http.Error(w, `{"error": "Title is required"}`, http.StatusBadRequest)
+	// с параметром occurrence меняется только одно вхождение серии
+	if r.FormValue("occurrence") != "" {
+		occurrence, err := parseTimeParam(r, "occurrence", params.Date.Location())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		// вхождение сохраняется отдельным событием, дальше клиент
+		// редактирует его по этому id и версии
+		event, err := cal.UpdateOccurrence(eventID, userID, occurrence, params)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		setETag(w, event)
+		w.Header().Set(eventIDHeader, strconv.Itoa(event.ID))
+		writeJSON(w, http.StatusOK, map[string]string{"result": "Occurrence updated"})
 		return
 	}
 
-	event.UserID = userID
-	event.Date = date
-	event.Title = title
-	events[eventID] = event
+	event, err := cal.UpdateEvent(eventID, userID, params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
+	setETag(w, event)
 	writeJSON(w, http.StatusOK, map[string]string{"result": "Event updated"})
 }
 
 func deleteEventHandler(w http.ResponseWriter, r *http.Request) {
 	eventID, err := parseIntParam(r, "id")
 	if err != nil {
-		http.Error(w, `{"error": "Invalid event id"}`, http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+
+	userID, err := requestUserID(r)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	if _, exists := events[eventID]; !exists {
-		http.Error(w, `{"error": "Event not found"}`, http.StatusServiceUnavailable)
+	version, err := parseVersion(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// с параметром occurrence отменяется только одно вхождение серии
+	if r.FormValue("occurrence") != "" {
+		loc, err := parseLocation(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		occurrence, err := parseTimeParam(r, "occurrence", loc)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := cal.CancelOccurrence(eventID, userID, occurrence, version); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"result": "Occurrence cancelled"})
+		return
+	}
+
+	if err := cal.DeleteEvent(eventID, userID, version); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	delete(events, eventID)
 	writeJSON(w, http.StatusOK, map[string]string{"result": "Event deleted"})
 }
 
-func getEventsForDayHandler(w http.ResponseWriter, r *http.Request) {
-	date, err := parseDateParam(r, "date")
+// eventsHandler — общий обработчик для выборок событий за период.
+// Границы периода считаются в зоне запрашивающего из параметра tz.
+func eventsHandler(query func(int, time.Time) ([]calendar.Event, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := requestUserID(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		loc, err := parseLocation(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		date, err := parseDateParam(r, "date", loc)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		result, err := query(userID, date)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeEvents(w, r, result)
+	}
+}
+
+// Курсор следующей страницы; ответ остается JSON массивом событий
+const nextCursorHeader = "X-Next-Cursor"
+
+const maxPageSize = 1000
+
+// writeEvents отдает страницу событий по параметрам limit и cursor.
+// Без limit отдаются все события после cursor.
+func writeEvents(w http.ResponseWriter, r *http.Request, events []calendar.Event) {
+	limit := 0
+	if r.FormValue("limit") != "" {
+		var err error
+		limit, err = parseIntParam(r, "limit")
+		if err != nil || limit < 1 || limit > maxPageSize {
+			writeError(w, &calendar.ValidationError{Msg: fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+			return
+		}
+	}
+
+	page, next, err := calendar.Paginate(events, r.FormValue("cursor"), limit)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid date"}`, http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	if next != "" {
+		w.Header().Set(nextCursorHeader, next)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
 
-	var result []Event
-	for _, event := range events {
-		if event.Date.Format("2006-01-02") == date.Format("2006-01-02") {
-			result = append(result, event)
+func getEventsForDayHandler(w http.ResponseWriter, r *http.Request) {
+	eventsHandler(cal.EventsForDay)(w, r)
+}
+
+// getEventsForWeekHandler отдает события недели ISO 8601 (с понедельника),
+// параметр week_start переносит начало недели на другой день
+func getEventsForWeekHandler(w http.ResponseWriter, r *http.Request) {
+	weekStart := time.Monday
+	if r.FormValue("week_start") != "" {
+		var err error
+		if weekStart, err = parseWeekdayParam(r, "week_start"); err != nil {
+			writeError(w, err)
+			return
 		}
 	}
+	eventsHandler(func(userID int, date time.Time) ([]calendar.Event, error) {
+		return cal.EventsForWeekStarting(userID, date, weekStart)
+	})(w, r)
+}
 
-	writeJSON(w, http.StatusOK, result)
+func getEventsForMonthHandler(w http.ResponseWriter, r *http.Request) {
+	eventsHandler(cal.EventsForMonth)(w, r)
 }
 
-func getEventsForWeekHandler(w http.ResponseWriter, r *http.Request) {
-	date, err := parseDateParam(r, "date")
+// eventsInRangeHandler отдает события, пересекающиеся с [from, to).
+// Границы — даты в зоне tz или моменты времени в RFC 3339.
+func eventsInRangeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid date"}`, http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	startOfWeek := date.AddDate(0, 0, -int(date.Weekday()))
-	endOfWeek := startOfWeek.AddDate(0, 0, 7)
+	loc, err := parseLocation(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-	var result []Event
-	for _, event := range events {
-		if event.Date.After(startOfWeek) && event.Date.Before(endOfWeek) {
-			result = append(result, event)
-		}
+	from, err := parseTimeParam(r, "from", loc)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	to, err := parseTimeParam(r, "to", loc)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	events, err := cal.EventsInRange(userID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeEvents(w, r, events)
 }
 
-func getEventsForMonthHandler(w http.ResponseWriter, r *http.Request) {
-	date, err := parseDateParam(r, "date")
+// searchEventsHandler ищет события по названию: q — текст запроса,
+// match=tokens ищет слова целиком, from и to ограничивают период
+func searchEventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid date"}`, http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	startOfMonth := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
-	endOfMonth := startOfMonth.AddDate(0, 1, 0)
+	loc, err := parseLocation(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-	var result []Event
-	for _, event := range events {
-		if event.Date.After(startOfMonth) && event.Date.Before(endOfMonth) {
-			result = append(result, event)
+	query := calendar.SearchQuery{Text: r.FormValue("q")}
+	switch r.FormValue("match") {
+	case "", "substring":
+	case "tokens":
+		query.Tokens = true
+	default:
+		writeError(w, &calendar.ValidationError{Msg: "invalid match"})
+		return
+	}
+	if query.From, query.To, err = parseRangeParams(r, loc); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	events, err := cal.SearchEvents(userID, query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeEvents(w, r, events)
+}
+
+// freeBusyHandler возвращает занятые интервалы пользователей user_ids
+// и общие свободные окна в периоде [from, to)
+func freeBusyHandler(w http.ResponseWriter, r *http.Request) {
+	userIDs, err := parseIntListParam(r, "user_ids")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	loc, err := parseLocation(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	from, err := parseTimeParam(r, "from", loc)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	to, err := parseTimeParam(r, "to", loc)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result, err := cal.FreeBusy(userIDs, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+}
+
+func newRouter() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/create_event", post(createEventHandler))
+	mux.Handle("/update_event", post(updateEventHandler))
+	mux.Handle("/delete_event", post(deleteEventHandler))
+	mux.Handle("/invite_attendees", post(inviteAttendeesHandler))
+	mux.Handle("/respond_to_invite", post(respondToInviteHandler))
+	mux.Handle("/batch", allowMethods(requireContentType(http.HandlerFunc(batchHandler), contentTypeJSON), http.MethodPost))
+	mux.Handle("/undo", post(undoHandler))
+	mux.Handle("/events_for_day", get(getEventsForDayHandler))
+	mux.Handle("/events_for_week", get(getEventsForWeekHandler))
+	mux.Handle("/events_for_month", get(getEventsForMonthHandler))
+	mux.Handle("/events_in_range", get(eventsInRangeHandler))
+	mux.Handle("/event_history", get(eventHistoryHandler))
+	mux.Handle("/search_events", get(searchEventsHandler))
+	mux.Handle("/free_busy", get(freeBusyHandler))
+	mux.Handle("/export_ics", get(exportICSHandler))
+	mux.Handle("/import_ics", allowMethods(requireContentType(http.HandlerFunc(importICSHandler), "text/calendar", contentTypeMultipart), http.MethodPost))
+	mux.Handle("/events/stream", get(eventsStreamHandler))
+	mux.Handle("/reminders_stream", get(reminderStreamHandler))
+	mux.Handle("/metrics", get(metricsHandler))
+	mux.Handle("/openapi.json", get(openAPIHandler))
+
+	return loggingMiddleware(metricsMiddleware(serverMetrics, mux, authMiddleware(auth, limiter, rateLimitMiddleware(limiter, mux))))
+}
+
+// run обслуживает запросы на listener и блокируется до отмены ctx.
+// После отмены сервер перестает принимать соединения, дожидается
+// текущих запросов и закрывает хранилище, сбрасывая его на диск.
+func run(ctx context.Context, config Config, listener net.Listener) error {
+	store, err := openStore(config)
+	if err != nil {
+		return fmt.Errorf("opening storage: %w", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Printf("Error closing storage: %v", err)
 		}
+	}()
+	cal = calendar.NewService(store)
+	reminderStream = newSSENotifier()
+	cal.LimitEventsPerUser(config.MaxEventsPerUser)
+	if auth, err = newAuthenticator(config); err != nil {
+		return fmt.Errorf("loading tokens: %w", err)
+	}
+	limiter = nil
+	if config.RateLimit > 0 {
+		limiter = newRateLimiter(config.RateLimit, config.RateBurst)
+	}
+
+	server := &http.Server{
+		Handler:           newRouter(),
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+	server.RegisterOnShutdown(reminderStream.close)
+	server.RegisterOnShutdown(cal.Changes().Close)
+
+	remindersCtx, stopReminders := context.WithCancel(ctx)
+	remindersDone := make(chan struct{})
+	go func() {
+		defer close(remindersDone)
+		runReminders(remindersCtx, newNotifier(config), config.ReminderInterval)
+	}()
+	defer func() {
+		stopReminders()
+		<-remindersDone
+	}()
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Server running on %s", listener.Addr())
+		errc <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	log.Printf("Shutting down, waiting up to %s for active requests", config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
 }
 
 func main() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/create_event", createEventHandler)
-	mux.HandleFunc("/update_event", updateEventHandler)
-	mux.HandleFunc("/delete_event", deleteEventHandler)
-	mux.HandleFunc("/events_for_day", getEventsForDayHandler)
-	mux.HandleFunc("/events_for_week", getEventsForWeekHandler)
-	mux.HandleFunc("/events_for_month", getEventsForMonthHandler)
-
-	loggedMux := loggingMiddleware(mux)
-
-	port := ":8080"
-	log.Printf("Server running on port %s", port)
-	log.Fatal(http.ListenAndServe(port, loggedMux))
+	config, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	setupLogging(config.LogFormat, os.Stderr)
+
+	if config.IssueToken != 0 {
+		fmt.Println(signToken([]byte(config.AuthSecret), config.IssueToken, time.Now().Add(config.TokenTTL)))
+		return
+	}
+
+	listener, err := net.Listen("tcp", ":"+config.Port)
+	if err != nil {
+		log.Fatalf("Error listening: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, config, listener); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+	log.Printf("Server stopped")
 }
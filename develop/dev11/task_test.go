@@ -1,16 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"httptask/calendar"
 )
 
-func resetEvents() {
-	events = make(map[int]Event)
-	nextID = 1
+func resetEvents() *calendar.MemoryStore {
+	store := calendar.NewMemoryStore()
+	cal = calendar.NewService(store)
+	return store
+}
+
+func mustParseDate(s string) time.Time {
+	date, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return date
 }
 
 func TestCreateEventHandler(t *testing.T) {
@@ -107,6 +126,7 @@ func TestDeleteEventHandler(t *testing.T) {
 
 	form = url.Values{}
 	form.Add("id", "1")
+	form.Add("user_id", "1")
 
 	req, err = http.NewRequest("POST", "/delete_event", strings.NewReader(form.Encode()))
 	if err != nil {
@@ -147,7 +167,7 @@ func TestGetEventsForDayHandler(t *testing.T) {
 	handler := http.HandlerFunc(createEventHandler)
 	handler.ServeHTTP(rr, req)
 
-	req, err = http.NewRequest("GET", "/events_for_day?date=2024-05-30", nil)
+	req, err = http.NewRequest("GET", "/events_for_day?user_id=1&date=2024-05-30", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -161,7 +181,7 @@ func TestGetEventsForDayHandler(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	expected := `[{"id":1,"user_id":1,"date":"2024-05-30T00:00:00Z","title":"Test Event"}]`
+	expected := `[{"id":1,"user_id":1,"date":"2024-05-30T00:00:00Z","title":"Test Event","version":1}]`
 	if strings.TrimSpace(rr.Body.String()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
@@ -185,7 +205,7 @@ func TestGetEventsForWeekHandler(t *testing.T) {
 	handler := http.HandlerFunc(createEventHandler)
 	handler.ServeHTTP(rr, req)
 
-	req, err = http.NewRequest("GET", "/events_for_week?date=2024-05-30", nil)
+	req, err = http.NewRequest("GET", "/events_for_week?user_id=1&date=2024-05-30", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -199,7 +219,7 @@ func TestGetEventsForWeekHandler(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	expected := `[{"id":1,"user_id":1,"date":"2024-05-30T00:00:00Z","title":"Test Event"}]`
+	expected := `[{"id":1,"user_id":1,"date":"2024-05-30T00:00:00Z","title":"Test Event","version":1}]`
 	if strings.TrimSpace(rr.Body.String()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
@@ -223,7 +243,7 @@ func TestGetEventsForMonthHandler(t *testing.T) {
 	handler := http.HandlerFunc(createEventHandler)
 	handler.ServeHTTP(rr, req)
 
-	req, err = http.NewRequest("GET", "/events_for_month?date=2024-05-30", nil)
+	req, err = http.NewRequest("GET", "/events_for_month?user_id=1&date=2024-05-30", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -237,8 +257,576 @@ func TestGetEventsForMonthHandler(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	expected := `[{"id":1,"user_id":1,"date":"2024-05-30T00:00:00Z","title":"Test Event"}]`
+	expected := `[{"id":1,"user_id":1,"date":"2024-05-30T00:00:00Z","title":"Test Event","version":1}]`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func postForm(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandlersConcurrent(t *testing.T) {
+	store := resetEvents()
+
+	const workers = 8
+	const perWorker = 50
+
+	var wg sync.WaitGroup
+	var deleted atomic.Int64
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				form := url.Values{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"Event"}}
+				if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusOK {
+					t.Errorf("create returned %d", rr.Code)
+				}
+
+				// id чужих событий: часть запросов попадет на уже удаленные
+				id := strconv.Itoa(i + 1)
+				form = url.Values{"id": {id}, "user_id": {"1"}, "date": {"2024-05-31"}, "title": {"Updated"}}
+				if rr := postForm(updateEventHandler, "/update_event", form); rr.Code != http.StatusOK && rr.Code != http.StatusServiceUnavailable {
+					t.Errorf("update returned %d", rr.Code)
+				}
+				switch rr := postForm(deleteEventHandler, "/delete_event", url.Values{"id": {id}, "user_id": {"1"}}); rr.Code {
+				case http.StatusOK:
+					deleted.Add(1)
+				case http.StatusServiceUnavailable:
+				default:
+					t.Errorf("delete returned %d", rr.Code)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	events, _ := store.List()
+	if len(events)+int(deleted.Load()) != workers*perWorker {
+		t.Errorf("Expected %d events in total, got %d stored and %d deleted", workers*perWorker, len(events), deleted.Load())
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	resetEvents()
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"Event"}})
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		form     url.Values
+		status   int
+		expected string
+	}{
+		{"invalid user_id", createEventHandler, url.Values{"user_id": {"x"}, "date": {"2024-05-30"}, "title": {"T"}}, http.StatusBadRequest, `{"error":"invalid user_id"}`},
+		{"invalid date", createEventHandler, url.Values{"user_id": {"1"}, "date": {"30.05.2024"}, "title": {"T"}}, http.StatusBadRequest, `{"error":"invalid date"}`},
+		{"empty title", createEventHandler, url.Values{"user_id": {"1"}, "date": {"2024-05-30"}}, http.StatusBadRequest, `{"error":"title is required"}`},
+		{"update missing", updateEventHandler, url.Values{"id": {"42"}, "user_id": {"1"}, "date": {"2024-05-30"}, "title": {"T"}}, http.StatusServiceUnavailable, `{"error":"event not found"}`},
+		{"update foreign", updateEventHandler, url.Values{"id": {"1"}, "user_id": {"2"}, "date": {"2024-05-30"}, "title": {"T"}}, http.StatusServiceUnavailable, `{"error":"event belongs to another user"}`},
+		{"delete foreign", deleteEventHandler, url.Values{"id": {"1"}, "user_id": {"2"}}, http.StatusServiceUnavailable, `{"error":"event belongs to another user"}`},
+		{"delete missing", deleteEventHandler, url.Values{"id": {"42"}, "user_id": {"1"}}, http.StatusServiceUnavailable, `{"error":"event not found"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postForm(tt.handler, "/", tt.form)
+			if rr.Code != tt.status {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tt.status)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("handler returned wrong content type: got %v", ct)
+			}
+			if strings.TrimSpace(rr.Body.String()) != tt.expected {
+				t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteErrorInternal(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeError(rr, &calendar.InternalError{Err: errors.New("disk full")})
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	// детали внутренних ошибок клиенту не отдаются
+	expected := `{"error":"internal error"}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestRecurringEventHandlers(t *testing.T) {
+	resetEvents()
+
+	form := url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Sync"}, "rrule": {"FREQ=WEEKLY;BYDAY=MO,TH"}}
+	if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusOK {
+		t.Fatalf("create returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	form = url.Values{"id": {"1"}, "user_id": {"1"}, "occurrence": {"2024-06-06"}}
+	if rr := postForm(deleteEventHandler, "/delete_event", form); rr.Code != http.StatusOK {
+		t.Fatalf("cancel occurrence returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/events_for_week?user_id=1&date=2024-06-04", nil)
+	rr := httptest.NewRecorder()
+	getEventsForWeekHandler(rr, req)
+
+	expected := `[{"id":1,"user_id":1,"date":"2024-06-03T00:00:00Z","title":"Sync","version":2,"rrule":"FREQ=WEEKLY;BYDAY=MO,TH","exdates":["2024-06-06T00:00:00Z"]}]`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	// измененное вхождение сохраняется отдельным событием с версией для If-Match
+	form = url.Values{"id": {"1"}, "user_id": {"1"}, "occurrence": {"2024-06-10"}, "date": {"2024-06-11"}, "title": {"Moved sync"}}
+	rr = postForm(updateEventHandler, "/update_event", form)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` || rr.Header().Get(eventIDHeader) != "2" {
+		t.Fatalf("update occurrence returned %d, ETag %q, id %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Header().Get(eventIDHeader), rr.Body.String())
+	}
+	form = url.Values{"id": {"2"}, "user_id": {"1"}, "date": {"2024-06-11"}, "title": {"Moved sync"}, "version": {"1"}}
+	if rr := postForm(updateEventHandler, "/update_event", form); rr.Code != http.StatusOK {
+		t.Errorf("update with occurrence ETag returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	form = url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Bad"}, "rrule": {"FREQ=YEARLY"}}
+	if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid rrule returned %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestTimedEventHandlers(t *testing.T) {
+	resetEvents()
+
+	form := url.Values{"user_id": {"1"}, "date": {"2024-05-30"}, "start_time": {"23:30"}, "duration": {"30m"}, "tz": {"Europe/Moscow"}, "title": {"Late call"}}
+	if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusOK {
+		t.Fatalf("create returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// в UTC событие приходится на 20:30 того же дня, в Москве — на 23:30
+	for _, query := range []string{"user_id=1&date=2024-05-30", "user_id=1&date=2024-05-30&tz=Europe/Moscow"} {
+		req := httptest.NewRequest("GET", "/events_for_day?"+query, nil)
+		rr := httptest.NewRecorder()
+		getEventsForDayHandler(rr, req)
+
+		expected := `[{"id":1,"user_id":1,"date":"2024-05-30T23:30:00+03:00","title":"Late call","version":1,"end":"2024-05-31T00:00:00+03:00","time_zone":"Europe/Moscow"}]`
+		if strings.TrimSpace(rr.Body.String()) != expected {
+			t.Errorf("%s: unexpected body: got %v want %v", query, rr.Body.String(), expected)
+		}
+	}
+
+	for _, form := range []url.Values{
+		{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"T"}, "tz": {"Mars/Olympus"}},
+		{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"T"}, "start_time": {"25:00"}},
+		{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"T"}, "start_time": {"10:00"}, "end_time": {"09:00"}},
+		{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"T"}, "end_time": {"09:00"}, "duration": {"1h"}},
+	} {
+		if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusBadRequest {
+			t.Errorf("%v: got %d, want %d", form, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestICSHandlers(t *testing.T) {
+	resetEvents()
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-05-30"}, "title": {"Test Event"}})
+
+	req := httptest.NewRequest("GET", "/export_ics?user_id=1", nil)
+	rr := httptest.NewRecorder()
+	exportICSHandler(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		t.Errorf("handler returned wrong content type: got %v", ct)
+	}
+	body := rr.Body.String()
+	for _, line := range []string{"BEGIN:VCALENDAR", "UID:1@httptask", "SUMMARY:Test Event", "DTSTART;VALUE=DATE:20240530"} {
+		if !strings.Contains(body, line+"\r\n") {
+			t.Errorf("export does not contain %q:\n%s", line, body)
+		}
+	}
+
+	req = httptest.NewRequest("POST", "/import_ics?user_id=2", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/calendar")
+	rr = httptest.NewRecorder()
+	importICSHandler(rr, req)
+
+	expected := `{"result":"Imported 1 events"}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	req = httptest.NewRequest("GET", "/events_for_day?user_id=2&date=2024-05-30", nil)
+	rr = httptest.NewRecorder()
+	getEventsForDayHandler(rr, req)
+
+	// событие, заданное только датой, импортируется как событие на весь день
+	expected = `[{"id":2,"user_id":2,"date":"2024-05-30T00:00:00Z","title":"Test Event","version":1,"uid":"1@httptask","all_day":true}]`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	// слишком длинная строка — ошибка файла, а не сервера
+	req = httptest.NewRequest("POST", "/import_ics?user_id=2", strings.NewReader("SUMMARY:"+strings.Repeat("x", 2<<20)+"\r\n"))
+	req.Header.Set("Content-Type", "text/calendar")
+	rr = httptest.NewRecorder()
+	importICSHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for too long line, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// файл больше maxICSSize отклоняется с 413, а не как внутренняя ошибка
+	huge := body + strings.Repeat("X-PADDING:x\r\n", maxICSSize/13)
+	req = httptest.NewRequest("POST", "/import_ics?user_id=2", strings.NewReader(huge))
+	req.Header.Set("Content-Type", "text/calendar")
+	rr = httptest.NewRecorder()
+	importICSHandler(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for oversized file, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", "huge.ics")
+	part.Write([]byte(huge))
+	mw.Close()
+	req = httptest.NewRequest("POST", "/import_ics?user_id=2", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr = httptest.NewRecorder()
+	importICSHandler(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for oversized multipart file, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestFreeBusyHandler(t *testing.T) {
+	resetEvents()
+
+	form := url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "start_time": {"10:00"}, "end_time": {"11:00"}, "title": {"Meeting"}, "reject_conflicts": {"true"}}
+	postForm(createEventHandler, "/create_event", form)
+	form.Set("start_time", "10:30")
+	if rr := postForm(createEventHandler, "/create_event", form); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("conflicting create returned %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	req := httptest.NewRequest("GET", "/free_busy?user_ids=1,2&from=2024-06-03T09:00:00Z&to=2024-06-03T12:00:00Z", nil)
+	rr := httptest.NewRecorder()
+	freeBusyHandler(rr, req)
+
+	expected := `{"result":{"busy":{"1":[{"start":"2024-06-03T10:00:00Z","end":"2024-06-03T11:00:00Z"}],"2":[]},` +
+		`"free":[{"start":"2024-06-03T09:00:00Z","end":"2024-06-03T10:00:00Z"},{"start":"2024-06-03T11:00:00Z","end":"2024-06-03T12:00:00Z"}]}}`
 	if strings.TrimSpace(rr.Body.String()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
+
+	req = httptest.NewRequest("GET", "/free_busy?user_ids=1,x&from=2024-06-03&to=2024-06-04", nil)
+	rr = httptest.NewRecorder()
+	freeBusyHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid user_ids returned %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestEventsPagination(t *testing.T) {
+	resetEvents()
+	for _, day := range []string{"2024-06-05", "2024-06-03", "2024-06-04"} {
+		cal.CreateEvent(1, calendar.EventParams{Date: mustParseDate(day), Title: "Event " + day})
+	}
+
+	var titles []string
+	cursor := ""
+	for {
+		req := httptest.NewRequest("GET", "/events_for_month?user_id=1&date=2024-06-01&limit=2&cursor="+cursor, nil)
+		rr := httptest.NewRecorder()
+		getEventsForMonthHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var page []calendar.Event
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range page {
+			titles = append(titles, event.Title)
+		}
+		if cursor = rr.Header().Get(nextCursorHeader); cursor == "" {
+			break
+		}
+	}
+	want := "Event 2024-06-03,Event 2024-06-04,Event 2024-06-05"
+	if got := strings.Join(titles, ","); got != want {
+		t.Errorf("Got %s, want %s", got, want)
+	}
+
+	for _, query := range []string{"limit=0", "limit=1001", "cursor=%25%25"} {
+		req := httptest.NewRequest("GET", "/events_for_month?user_id=1&date=2024-06-01&"+query, nil)
+		rr := httptest.NewRecorder()
+		getEventsForMonthHandler(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestSearchEventsHandler(t *testing.T) {
+	resetEvents()
+	cal.CreateEvent(1, calendar.EventParams{Date: mustParseDate("2024-06-03"), Title: "Sprint planning"})
+	cal.CreateEvent(1, calendar.EventParams{Date: mustParseDate("2024-07-03"), Title: "Sprint review"})
+
+	tests := []struct {
+		query string
+		code  int
+		count int
+	}{
+		{"q=SPRINT", http.StatusOK, 2},
+		{"q=sprint&from=2024-06-01&to=2024-07-01", http.StatusOK, 1},
+		{"q=review+sprint&match=tokens", http.StatusOK, 1},
+		{"q=sprint&limit=1", http.StatusOK, 1},
+		{"q=", http.StatusBadRequest, 0},
+		{"q=sprint&match=fuzzy", http.StatusBadRequest, 0},
+		{"q=sprint&from=2024-06-01", http.StatusBadRequest, 0},
+		{"q=sprint&from=2024-07-01&to=2024-06-01", http.StatusBadRequest, 0},
+		{"q=sprint&from=2020-01-01&to=2030-01-01", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/search_events?user_id=1&"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		searchEventsHandler(rr, req)
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d: %s", tt.query, tt.code, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var events []calendar.Event
+		json.Unmarshal(rr.Body.Bytes(), &events)
+		if len(events) != tt.count {
+			t.Errorf("%s: expected %d events, got %+v", tt.query, tt.count, events)
+		}
+	}
+}
+
+func TestWeekStartAndRangeHandlers(t *testing.T) {
+	resetEvents()
+	captureLogs(t)
+	// 2 июня 2024 — воскресенье
+	cal.CreateEvent(1, calendar.EventParams{Date: mustParseDate("2024-06-02"), Title: "Sunday"})
+	cal.CreateEvent(1, calendar.EventParams{Date: mustParseDate("2024-06-03"), Title: "Monday"})
+
+	tests := []struct {
+		path string
+		code int
+		want string
+	}{
+		{"/events_for_week?user_id=1&date=2024-06-04", http.StatusOK, "Monday"},
+		{"/events_for_week?user_id=1&date=2024-06-04&week_start=sunday", http.StatusOK, "Sunday,Monday"},
+		{"/events_for_week?user_id=1&date=2024-06-04&week_start=SU", http.StatusOK, "Sunday,Monday"},
+		{"/events_for_week?user_id=1&date=2024-06-04&week_start=funday", http.StatusBadRequest, ""},
+		{"/events_in_range?user_id=1&from=2024-06-02&to=2024-06-03", http.StatusOK, "Sunday"},
+		{"/events_in_range?user_id=1&from=2024-06-02T12:00:00Z&to=2024-06-04", http.StatusOK, "Monday"},
+		{"/events_in_range?user_id=1&from=2024-06-04&to=2024-06-02", http.StatusBadRequest, ""},
+		{"/events_in_range?user_id=1&from=2024-06-02", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rr := serveRouter(httptest.NewRequest("GET", tt.path, nil))
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d: %s", tt.path, tt.code, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var events []calendar.Event
+		json.Unmarshal(rr.Body.Bytes(), &events)
+		var titles []string
+		for _, event := range events {
+			titles = append(titles, event.Title)
+		}
+		if got := strings.Join(titles, ","); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestVersionHandlers(t *testing.T) {
+	resetEvents()
+
+	rr := postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Standup"}})
+	if etag := rr.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf(`Expected ETag "1" after create, got %q`, etag)
+	}
+	if id := rr.Header().Get(eventIDHeader); id != "1" {
+		t.Fatalf(`Expected event id "1" after create, got %q`, id)
+	}
+
+	update := func(ifMatch string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("user_id", "1")
+		form.Set("id", "1")
+		form.Set("date", "2024-06-03")
+		form.Set("title", "Retro")
+		req := httptest.NewRequest("POST", "/update_event", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		updateEventHandler(rr, req)
+		return rr
+	}
+
+	if rr := update(`"1"`, url.Values{}); rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected 200 with ETag \"2\", got %d %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+	if rr := update(`"1"`, url.Values{}); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for stale If-Match, got %d", rr.Code)
+	}
+	if rr := update("", url.Values{"version": {"1"}}); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for stale version field, got %d", rr.Code)
+	}
+	if rr := update(`"abc"`, url.Values{}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid If-Match, got %d", rr.Code)
+	}
+	if rr := update("*", url.Values{}); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for If-Match: *, got %d", rr.Code)
+	}
+
+	if rr := postForm(deleteEventHandler, "/delete_event", url.Values{"user_id": {"1"}, "id": {"1"}, "version": {"2"}}); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for stale delete, got %d", rr.Code)
+	}
+	if rr := postForm(deleteEventHandler, "/delete_event", url.Values{"user_id": {"1"}, "id": {"1"}, "version": {"3"}}); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for delete with current version, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHistoryAndUndoHandlers(t *testing.T) {
+	resetEvents()
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Standup"}})
+	postForm(updateEventHandler, "/update_event", url.Values{"user_id": {"1"}, "id": {"1"}, "date": {"2024-06-03"}, "title": {"Retro"}})
+
+	req := httptest.NewRequest("GET", "/event_history?id=1&user_id=1", nil)
+	rr := httptest.NewRecorder()
+	eventHistoryHandler(rr, req)
+	var history struct {
+		Result []calendar.AuditRecord `json:"result"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &history); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(history.Result) != 2 || history.Result[1].Before.Title != "Standup" || history.Result[1].After.Title != "Retro" {
+		t.Errorf("Unexpected history: %s", rr.Body.String())
+	}
+
+	rr = postForm(undoHandler, "/undo", url.Values{"user_id": {"1"}})
+	if expected := `{"result":"Undone 1 changes"}`; strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("Unexpected undo response: %s", rr.Body.String())
+	}
+	if events, _ := cal.EventsForDay(1, mustParseDate("2024-06-03")); len(events) != 1 || events[0].Title != "Standup" {
+		t.Errorf("Expected update to be undone, got %+v", events)
+	}
+
+	if rr := postForm(undoHandler, "/undo", url.Values{"user_id": {"2"}}); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when nothing to undo, got %d", rr.Code)
+	}
+	req = httptest.NewRequest("GET", "/event_history?id=1&user_id=2", nil)
+	rr = httptest.NewRecorder()
+	eventHistoryHandler(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for another user's history, got %d", rr.Code)
+	}
+}
+
+func TestAttendeeHandlers(t *testing.T) {
+	resetEvents()
+	postForm(createEventHandler, "/create_event", url.Values{"user_id": {"1"}, "date": {"2024-06-03"}, "title": {"Planning"}})
+
+	rr := postForm(inviteAttendeesHandler, "/invite_attendees", url.Values{"user_id": {"1"}, "id": {"1"}, "attendees": {"2,3"}, "version": {"1"}})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Unexpected invite response %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = postForm(respondToInviteHandler, "/respond_to_invite", url.Values{"user_id": {"2"}, "id": {"1"}, "status": {"accepted"}})
+	if expected := `{"result":"Response saved"}`; strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("Unexpected respond response: %s", rr.Body.String())
+	}
+
+	rr = serveRouter(httptest.NewRequest("GET", "/events_for_month?user_id=2&date=2024-06-01", nil))
+	expected := `[{"id":1,"user_id":1,"date":"2024-06-03T00:00:00Z","title":"Planning","version":3,` +
+		`"attendees":[{"user_id":2,"status":"accepted"},{"user_id":3,"status":"pending"}]}]`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("Unexpected attendee events: got %s want %s", rr.Body.String(), expected)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		form    url.Values
+		status  int
+	}{
+		{"invalid attendees", inviteAttendeesHandler, url.Values{"user_id": {"1"}, "id": {"1"}, "attendees": {"2,x"}}, http.StatusBadRequest},
+		{"not owner", inviteAttendeesHandler, url.Values{"user_id": {"2"}, "id": {"1"}, "attendees": {"4"}}, http.StatusServiceUnavailable},
+		{"stale version", inviteAttendeesHandler, url.Values{"user_id": {"1"}, "id": {"1"}, "attendees": {"4"}, "version": {"1"}}, http.StatusServiceUnavailable},
+		{"invalid status", respondToInviteHandler, url.Values{"user_id": {"2"}, "id": {"1"}, "status": {"maybe"}}, http.StatusBadRequest},
+		{"not invited", respondToInviteHandler, url.Values{"user_id": {"4"}, "id": {"1"}, "status": {"accepted"}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		if rr := postForm(tt.handler, "/", tt.form); rr.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestEventDetailsHandlers(t *testing.T) {
+	resetEvents()
+	rr := postForm(createEventHandler, "/create_event", url.Values{
+		"user_id":     {"1"},
+		"date":        {"2024-06-03"},
+		"title":       {"Offsite"},
+		"description": {"Quarterly planning"},
+		"location":    {"Room 1"},
+		"color":       {"#3366ff"},
+		"categories":  {"work, meeting"},
+		"all_day":     {"true"},
+		"duration":    {"48h"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected create response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serveRouter(httptest.NewRequest("GET", "/events_for_day?user_id=1&date=2024-06-04", nil))
+	expected := `[{"id":1,"user_id":1,"date":"2024-06-03T00:00:00Z","title":"Offsite","version":1,"end":"2024-06-05T00:00:00Z",` +
+		`"description":"Quarterly planning","location":"Room 1","color":"#3366ff","categories":["work","meeting"],"all_day":true}]`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("Unexpected events: got %s want %s", rr.Body.String(), expected)
+	}
+
+	// одни и те же правила отклоняют данные при создании и изменении
+	invalid := []struct {
+		name  string
+		form  url.Values
+		error string
+	}{
+		{"long title", url.Values{"title": {strings.Repeat("a", 201)}}, "title must be at most 200 characters"},
+		{"category", url.Values{"categories": {"work,party"}}, "category must be one of work, personal, meeting, travel, holiday, birthday, health, other"},
+		{"color", url.Values{"color": {"red"}}, "invalid color"},
+		{"date bounds", url.Values{"date": {"2300-01-01"}}, "date must be between 1900-01-01 and 2200-01-01"},
+		{"all-day time", url.Values{"all_day": {"true"}, "start_time": {"10:00"}}, "all-day event must start and end at midnight"},
+		{"all_day flag", url.Values{"all_day": {"maybe"}}, "invalid all_day"},
+	}
+	for _, tt := range invalid {
+		for _, handler := range []http.HandlerFunc{createEventHandler, updateEventHandler} {
+			form := url.Values{"user_id": {"1"}, "id": {"1"}, "date": {"2024-06-03"}, "title": {"Offsite"}}
+			for key, values := range tt.form {
+				form[key] = values
+			}
+			rr := postForm(handler, "/", form)
+			if expected := `{"error":"` + tt.error + `"}`; rr.Code != http.StatusBadRequest || strings.TrimSpace(rr.Body.String()) != expected {
+				t.Errorf("%s: got %d %s, want 400 %s", tt.name, rr.Code, rr.Body.String(), expected)
+			}
+		}
+	}
 }